@@ -0,0 +1,114 @@
+package calendarservice
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+)
+
+// caldavSource queries a CalDAV calendar collection (Nextcloud, Radicale, ...)
+// with a calendar-query REPORT
+type caldavSource struct {
+	url         string
+	username    string
+	password    string
+	httpClient  HTTPClient
+	retryConfig retry.Config
+}
+
+// caldavQuery is the calendar-query REPORT body (RFC 4791 section 7.8)
+const caldavQuery = `<?xml version="1.0" encoding="utf-8" ?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop>
+    <D:getetag/>
+    <C:calendar-data/>
+  </D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT">
+        <C:time-range start="%s" end="%s"/>
+      </C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+</C:calendar-query>`
+
+// caldavMultistatus is the subset of a 207 Multi-Status response we read
+type caldavMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+func (s *caldavSource) Events(ctx context.Context, timeMin, timeMax time.Time) ([]models.CalendarEvent, error) {
+	multistatus, err := s.query(ctx, timeMin, timeMax)
+	if err != nil {
+		return nil, err
+	}
+
+	var cals []*icsComponent
+	for _, resp := range multistatus.Responses {
+		for _, propstat := range resp.Propstats {
+			if propstat.Prop.CalendarData == "" || !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+			cal, err := parseICS(strings.NewReader(propstat.Prop.CalendarData))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse calendar data of %s: %v", resp.Href, err)
+			}
+			cals = append(cals, cal)
+		}
+	}
+
+	return calendarEventsFromICS(cals, timeMin, timeMax), nil
+}
+
+// query performs the calendar-query REPORT for [timeMin, timeMax)
+func (s *caldavSource) query(ctx context.Context, timeMin, timeMax time.Time) (*caldavMultistatus, error) {
+	body := fmt.Sprintf(caldavQuery,
+		timeMin.UTC().Format(icsDateTimeLayout)+"Z",
+		timeMax.UTC().Format(icsDateTimeLayout)+"Z",
+	)
+
+	return retry.DoWithResult(ctx, s.retryConfig, func() (*caldavMultistatus, error) {
+		req, err := http.NewRequestWithContext(ctx, "REPORT", s.url, strings.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+		req.Header.Set("Depth", "1")
+		if s.username != "" {
+			req.SetBasicAuth(s.username, s.password)
+		}
+
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query caldav calendar: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusMultiStatus {
+			if retry.IsTransientStatusCode(resp.StatusCode) {
+				return nil, fmt.Errorf("caldav server returned transient error: status code %d", resp.StatusCode)
+			}
+			return nil, fmt.Errorf("caldav server error: status code %d", resp.StatusCode)
+		}
+
+		var multistatus caldavMultistatus
+		if err := xml.NewDecoder(resp.Body).Decode(&multistatus); err != nil {
+			return nil, fmt.Errorf("failed to parse caldav response: %v", err)
+		}
+		return &multistatus, nil
+	})
+}
@@ -0,0 +1,117 @@
+package calendarservice
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+)
+
+// newTestCalDAVServer stands in for a CalDAV server holding one single and one
+// recurring event. It checks the request the way a real server would.
+func newTestCalDAVServer(t *testing.T) *httptest.Server {
+	objects := []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:dentist@test\r\nSUMMARY:Dentist\r\n" +
+			"DTSTART:20240110T150000Z\r\nDTEND:20240110T160000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:piano@test\r\nSUMMARY:Piano\r\n" +
+			"DTSTART:20240102T220000Z\r\nDTEND:20240102T230000Z\r\nRRULE:FREQ=WEEKLY\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "REPORT" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "pi" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Depth") != "1" {
+			http.Error(w, "expected Depth: 1", http.StatusBadRequest)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `<C:time-range start="20240107T000000Z" end="20240114T000000Z"/>`) {
+			http.Error(w, "unexpected time range", http.StatusBadRequest)
+			return
+		}
+
+		var out strings.Builder
+		out.WriteString(`<?xml version="1.0" encoding="utf-8"?><d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">`)
+		for i, obj := range objects {
+			out.WriteString(`<d:response><d:href>/calendars/pi/family/` + string(rune('a'+i)) + `.ics</d:href>`)
+			out.WriteString(`<d:propstat><d:prop><d:getetag>"1"</d:getetag><cal:calendar-data>`)
+			xml.EscapeText(&out, []byte(obj))
+			out.WriteString(`</cal:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`)
+		}
+		out.WriteString(`</d:multistatus>`)
+
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(out.String()))
+	}))
+}
+
+func TestCalDAVSource(t *testing.T) {
+	ts := newTestCalDAVServer(t)
+	defer ts.Close()
+
+	passwordFile := filepath.Join(t.TempDir(), "caldav_password")
+	if err := os.WriteFile(passwordFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatalf("Failed to write password file: %v", err)
+	}
+
+	sources, err := newCalendarSources([]models.CalendarEntry{{
+		Type:         "caldav",
+		URL:          ts.URL + "/calendars/pi/family/",
+		Username:     "pi",
+		PasswordFile: passwordFile,
+		Color:        "orange",
+	}}, nil, ts.Client(), retry.DefaultConfig(), nil, time.Now)
+	if err != nil {
+		t.Fatalf("Failed to create sources: %v", err)
+	}
+
+	timeMin := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	timeMax := time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)
+	events, err := sources[0].source.Events(context.Background(), timeMin, timeMax)
+	if err != nil {
+		t.Fatalf("Failed to fetch events: %v", err)
+	}
+
+	var ids []string
+	for _, ev := range events {
+		ids = append(ids, ev.ID)
+	}
+	if want := "piano@test_20240109T220000Z,dentist@test"; strings.Join(ids, ",") != want {
+		t.Errorf("Expected events %s, got %s", want, strings.Join(ids, ","))
+	}
+}
+
+func TestCalDAVSourceUnauthorized(t *testing.T) {
+	ts := newTestCalDAVServer(t)
+	defer ts.Close()
+
+	src := &caldavSource{
+		url:         ts.URL,
+		username:    "pi",
+		password:    "wrong",
+		httpClient:  ts.Client(),
+		retryConfig: retry.DefaultConfig(),
+	}
+
+	_, err := src.Events(context.Background(), time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC))
+	if err == nil || !strings.Contains(err.Error(), "status code 401") {
+		t.Errorf("Expected a 401 error, got %v", err)
+	}
+}
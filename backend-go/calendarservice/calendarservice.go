@@ -4,13 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/coreyk/piinky/backend-go/models"
 	"github.com/coreyk/piinky/backend-go/retry"
+	"github.com/coreyk/piinky/backend-go/snapshot"
 	"golang.org/x/oauth2/google"
 	calendar "google.golang.org/api/calendar/v3"
 	"google.golang.org/api/option"
@@ -19,11 +20,30 @@ import (
 // Request timeout for calendar operations
 const calendarRequestTimeout = 60 * time.Second
 
+// Default interval between background refreshes
+const defaultRefreshInterval = 15 * time.Minute
+
+// HTTP timeout for fetching ics feeds
+const icsHTTPTimeout = 30 * time.Second
+
+// HTTPClient interface
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
 type Service struct {
-	calendarSvc    *calendar.Service
+	sources        []calendarSource
 	calendarConfig models.CalendarConfig
+	classifier     *eventClassifier // Assigns event categories, may be nil
+	people         *peopleTagger    // Tags events with family members, may be nil
+	location       *time.Location   // Timezone of the display, time.Local if nil
 	retryConfig    retry.Config
+	snapshots      *snapshot.Store
 	now            func() time.Time // For testing purposes
+
+	mu          sync.Mutex
+	lastSuccess map[string]time.Time // Last successful fetch per calendar
+	current     *calendarSnapshot    // Result of the last refresh
 }
 
 func NewService() (*Service, error) {
@@ -44,6 +64,70 @@ func NewService() (*Service, error) {
 		return nil, fmt.Errorf("failed to parse calendar config: %v", err)
 	}
 
+	location, err := loadLocation(calendarConfig.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateWindowMode(calendarConfig.WindowMode); err != nil {
+		return nil, err
+	}
+	if err := validateDuplicatePolicy(calendarConfig.DuplicatePolicy); err != nil {
+		return nil, err
+	}
+	classifier, err := newEventClassifier(calendarConfig.Categories)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure categories: %v", err)
+	}
+	people, err := newPeopleTagger(calendarConfig.People)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure people: %v", err)
+	}
+
+	retryConfig := retry.DefaultConfig()
+	httpClient := &http.Client{
+		Timeout: icsHTTPTimeout,
+	}
+
+	snapshots, err := snapshot.NewStoreFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	svc := &Service{
+		calendarConfig: calendarConfig,
+		classifier:     classifier,
+		people:         people,
+		location:       location,
+		retryConfig:    retryConfig,
+		snapshots:      snapshots,
+		now:            time.Now,
+	}
+
+	// Sources read the clock through the service, so SetNowFunc applies to them
+	svc.sources, err = newCalendarSources(calendarConfig.Calendars, newGoogleCalendarService, httpClient, retryConfig, snapshots, func() time.Time { return svc.now() })
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure calendars: %v", err)
+	}
+
+	return svc, nil
+}
+
+// loadLocation resolves the configured IANA timezone name, defaulting to the
+// system timezone
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %v", name, err)
+	}
+	return location, nil
+}
+
+// newGoogleCalendarService creates a Google Calendar client from the service
+// account credentials file
+func newGoogleCalendarService() (*calendar.Service, error) {
 	calendarCredentialsFile := os.Getenv("GOOGLE_CALENDAR_CREDENTIALS_FILE")
 	if calendarCredentialsFile == "" {
 		calendarCredentialsFile = "../google_credentials.json"
@@ -65,12 +149,7 @@ func NewService() (*Service, error) {
 		return nil, fmt.Errorf("failed to create calendar service: %v", err)
 	}
 
-	return &Service{
-		calendarSvc:    calendarSvc,
-		calendarConfig: calendarConfig,
-		retryConfig:    retry.DefaultConfig(),
-		now:            time.Now,
-	}, nil
+	return calendarSvc, nil
 }
 
 // SetNowFunc allows setting a custom time function for testing
@@ -84,77 +163,117 @@ func (s *Service) HandleGetCalendar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	snap, err := s.snapshotFor(r).forPerson(r.URL.Query().Get("person"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, snap.response())
+}
+
+// snapshotFor returns the snapshot of the last refresh, refreshing
+// synchronously if nothing has been fetched yet, e.g. before the poller's
+// first run
+func (s *Service) snapshotFor(r *http.Request) *calendarSnapshot {
+	if snap := s.currentSnapshot(); snap != nil {
+		return snap
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), calendarRequestTimeout)
 	defer cancel()
+	return s.refresh(ctx)
+}
 
-	// Get current time in local timezone
-	today := s.now().In(time.Local)
-
-	// Calculate the start of the week based on the configuration
-	var weekStart time.Time
-	if s.calendarConfig.StartOnSunday {
-		// If today is Sunday (0), we want to stay on this Sunday
-		daysToSubtract := int(today.Weekday())
-		if daysToSubtract == 0 {
-			weekStart = today
-		} else {
-			weekStart = today.AddDate(0, 0, -daysToSubtract)
-		}
-	} else {
-		// For Monday start: if today is Monday (1), we stay on Monday
-		daysToSubtract := int(today.Weekday())
-		if daysToSubtract == 0 { // Sunday
-			daysToSubtract = 6
-		} else {
-			daysToSubtract--
-		}
-		weekStart = today.AddDate(0, 0, -daysToSubtract)
+func writeJSON(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
+		return
 	}
+}
 
-	// Ensure we start at beginning of day in local time
-	weekStart = time.Date(weekStart.Year(), weekStart.Month(), weekStart.Day(), 0, 0, 0, 0, time.Local)
+// timezone returns the timezone of the display
+func (s *Service) timezone() *time.Location {
+	if s.location == nil {
+		return time.Local
+	}
+	return s.location
+}
 
-	timeMin := weekStart.Format(time.RFC3339)
-	timeMax := today.AddDate(0, 0, s.calendarConfig.NumberOfWeeks*7).Format(time.RFC3339)
+// calendarSnapshot is the outcome of a refresh: the events of the window and
+// the status of every calendar
+type calendarSnapshot struct {
+	config    models.CalendarConfig
+	start     time.Time // Midnight starting the first day of the window
+	end       time.Time // Midnight after the last day
+	events    []models.CalendarEvent
+	statuses  []models.CalendarStatus
+	fetchedAt time.Time
+}
 
-	var events []*calendar.Event
-	for _, cal := range s.calendarConfig.Calendars {
-		result, err := s.fetchCalendarEventsWithRetry(ctx, cal.CalendarID, timeMin, timeMax)
-		if err != nil {
-			log.Printf("Calendar API error for %s: %v", cal.CalendarID, err)
-			http.Error(w, fmt.Sprintf("Failed to fetch calendar events: %v", err), http.StatusInternalServerError)
-			return
-		}
+// refresh fetches all calendars for the current window and replaces the
+// in-memory snapshot served by the handlers
+func (s *Service) refresh(ctx context.Context) *calendarSnapshot {
+	start, end := s.window()
+	events, statuses := s.fetchAll(ctx, start, end)
 
-		for _, event := range result.Items {
-			event.ColorId = cal.Color
-			events = append(events, event)
-		}
+	snap := &calendarSnapshot{
+		config:    s.calendarConfig,
+		start:     start,
+		end:       end,
+		events:    events,
+		statuses:  statuses,
+		fetchedAt: s.now(),
 	}
 
-	response := map[string]interface{}{
-		"startDate":     timeMin,
-		"endDate":       timeMax,
-		"numberOfWeeks": s.calendarConfig.NumberOfWeeks,
-		"startOnSunday": s.calendarConfig.StartOnSunday,
-		"events":        events,
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = snap
+	return snap
+}
+
+// currentSnapshot returns the snapshot of the last refresh, or nil
+func (s *Service) currentSnapshot() *calendarSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current
+}
+
+// stale reports whether any calendar is served from its last-known-good
+// snapshot
+func (c *calendarSnapshot) stale() bool {
+	for _, status := range c.statuses {
+		if status.Stale {
+			return true
+		}
 	}
+	return false
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
-		return
+// truncated reports whether any calendar hit its event cap
+func (c *calendarSnapshot) truncated() bool {
+	for _, status := range c.statuses {
+		if status.Truncated {
+			return true
+		}
 	}
+	return false
 }
 
-func (s *Service) fetchCalendarEventsWithRetry(ctx context.Context, calendarID, timeMin, timeMax string) (*calendar.Events, error) {
-	return retry.DoWithResult(ctx, s.retryConfig, func() (*calendar.Events, error) {
-		return s.calendarSvc.Events.List(calendarID).
-			Context(ctx).
-			TimeMin(timeMin).
-			TimeMax(timeMax).
-			SingleEvents(true).
-			OrderBy("startTime").
-			Do()
-	})
+// response builds the /api/calendar response, CalendarResponse in
+// frontend/src/types/calendar.ts
+func (c *calendarSnapshot) response() map[string]interface{} {
+	return map[string]interface{}{
+		"version":        models.CalendarAPIVersion,
+		"startDate":      c.start.Format(time.RFC3339),
+		"endDate":        c.end.Format(time.RFC3339),
+		"numberOfWeeks":  c.numberOfWeeks(),
+		"startOnSunday":  c.config.StartOnSunday,
+		"timezone":       c.start.Location().String(),
+		"events":         c.events,
+		"calendarStatus": c.statuses,
+		"stale":          c.stale(),
+		"truncated":      c.truncated(),
+		"fetchedAt":      c.fetchedAt.Format(time.RFC3339),
+	}
 }
@@ -1,15 +1,21 @@
 package calendarservice
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/snapshot"
 )
 
 func setupTestConfig(t *testing.T) (string, func()) {
@@ -23,10 +29,7 @@ func setupTestConfig(t *testing.T) (string, func()) {
 	tempConfig := models.CalendarConfig{
 		StartOnSunday: true,
 		NumberOfWeeks: 2,
-		Calendars: []struct {
-			CalendarID string `json:"calendar_id"`
-			Color      string `json:"color"`
-		}{
+		Calendars: []models.CalendarEntry{
 			{
 				CalendarID: "primary",
 				Color:      "1",
@@ -71,12 +74,14 @@ func setupTestConfig(t *testing.T) (string, func()) {
 	// Set environment variables
 	os.Setenv("GOOGLE_CALENDAR_CONFIG_FILE", configPath)
 	os.Setenv("GOOGLE_CALENDAR_CREDENTIALS_FILE", credsPath)
+	os.Setenv("SNAPSHOT_DIR", filepath.Join(tmpDir, "snapshots"))
 
 	// Return cleanup function
 	cleanup := func() {
 		os.RemoveAll(tmpDir)
 		os.Unsetenv("GOOGLE_CALENDAR_CONFIG_FILE")
 		os.Unsetenv("GOOGLE_CALENDAR_CREDENTIALS_FILE")
+		os.Unsetenv("SNAPSHOT_DIR")
 	}
 
 	return tmpDir, cleanup
@@ -173,3 +178,459 @@ func TestWeekStartCalculation(t *testing.T) {
 		})
 	}
 }
+
+// fakeSource is a Source returning fixed events
+type fakeSource struct {
+	events []models.CalendarEvent
+	err    error
+}
+
+func (f *fakeSource) Events(ctx context.Context, timeMin, timeMax time.Time) ([]models.CalendarEvent, error) {
+	return f.events, f.err
+}
+
+func TestWindowUsesConfiguredTimezone(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone America/New_York not available: %v", err)
+	}
+
+	testCases := []struct {
+		name          string
+		startOnSunday bool
+		now           time.Time
+		expectedStart string
+		expectedEnd   string
+	}{
+		{
+			// Tuesday 01:30 UTC is still Monday evening in New York
+			name:          "UTC clock ahead of local date",
+			startOnSunday: false,
+			now:           time.Date(2024, 1, 9, 1, 30, 0, 0, time.UTC),
+			expectedStart: "2024-01-08T00:00:00-05:00",
+			expectedEnd:   "2024-01-15T00:00:00-05:00",
+		},
+		{
+			// Clocks spring forward on Sunday March 10
+			name:          "Spring forward week",
+			startOnSunday: true,
+			now:           time.Date(2024, 3, 13, 16, 0, 0, 0, time.UTC),
+			expectedStart: "2024-03-10T00:00:00-05:00",
+			expectedEnd:   "2024-03-17T00:00:00-04:00",
+		},
+		{
+			// Clocks fall back on Sunday November 3
+			name:          "Fall back week",
+			startOnSunday: false,
+			now:           time.Date(2024, 11, 3, 17, 0, 0, 0, time.UTC),
+			expectedStart: "2024-10-28T00:00:00-04:00",
+			expectedEnd:   "2024-11-04T00:00:00-05:00",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &Service{
+				calendarConfig: models.CalendarConfig{StartOnSunday: tc.startOnSunday, NumberOfWeeks: 1, Timezone: "America/New_York"},
+				location:       ny,
+				now:            func() time.Time { return tc.now },
+			}
+
+			weekStart, weekEnd := svc.window()
+			if got := weekStart.Format(time.RFC3339); got != tc.expectedStart {
+				t.Errorf("Expected start %s, got %s", tc.expectedStart, got)
+			}
+			if got := weekEnd.Format(time.RFC3339); got != tc.expectedEnd {
+				t.Errorf("Expected end %s, got %s", tc.expectedEnd, got)
+			}
+		})
+	}
+}
+
+func TestCalendarGridAcrossDSTTransition(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone America/New_York not available: %v", err)
+	}
+
+	svc := &Service{
+		calendarConfig: models.CalendarConfig{StartOnSunday: true, NumberOfWeeks: 1, Timezone: "America/New_York"},
+		location:       ny,
+		sources: []calendarSource{{
+			config: models.CalendarEntry{CalendarID: "family", Color: "blue"},
+			source: &fakeSource{events: []models.CalendarEvent{
+				{ID: "brunch", Summary: "Brunch", Start: models.EventTime{DateTime: "2024-03-10T11:00:00-04:00"}, End: models.EventTime{DateTime: "2024-03-10T12:00:00-04:00"}},
+				{ID: "late", Summary: "Late show", Start: models.EventTime{DateTime: "2024-03-11T03:30:00Z"}, End: models.EventTime{DateTime: "2024-03-11T03:45:00Z"}},
+				{ID: "holiday", Summary: "Holiday", Start: models.EventTime{Date: "2024-03-11"}, End: models.EventTime{Date: "2024-03-12"}},
+			}},
+		}},
+		// 02:00 UTC on Monday is Sunday evening in New York
+		now: func() time.Time { return time.Date(2024, 3, 11, 2, 0, 0, 0, time.UTC) },
+	}
+
+	w := httptest.NewRecorder()
+	svc.HandleGetCalendarGrid(w, httptest.NewRequest(http.MethodGet, "/api/calendar/grid", nil))
+
+	var response models.CalendarData
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.Timezone != "America/New_York" {
+		t.Errorf("Expected timezone America/New_York, got %s", response.Timezone)
+	}
+	if response.StartDate != "2024-03-10T00:00:00-05:00" {
+		t.Errorf("Expected the week to start at local midnight before the transition, got %s", response.StartDate)
+	}
+
+	expected := []string{"2024-03-10", "2024-03-11", "2024-03-12", "2024-03-13", "2024-03-14", "2024-03-15", "2024-03-16"}
+	for i, day := range response.Events {
+		if day.Date != expected[i] {
+			t.Errorf("Expected day %d to be %s, got %s", i, expected[i], day.Date)
+		}
+	}
+
+	sunday, monday := response.Events[0], response.Events[1]
+	if !sunday.IsToday || monday.IsToday {
+		t.Errorf("Expected Sunday March 10 to be today in New York")
+	}
+
+	// The late show is 23:30 EDT on Sunday although it is Monday in UTC, and
+	// the holiday is only on Monday
+	var sundayEvents, mondayEvents []string
+	for _, ev := range sunday.Events {
+		sundayEvents = append(sundayEvents, ev.ID)
+	}
+	for _, ev := range monday.Events {
+		mondayEvents = append(mondayEvents, ev.ID)
+	}
+	if got := strings.Join(sundayEvents, ","); got != "brunch,late" {
+		t.Errorf("Expected brunch,late on Sunday, got %s", got)
+	}
+	if got := strings.Join(mondayEvents, ","); got != "holiday" {
+		t.Errorf("Expected holiday on Monday, got %s", got)
+	}
+}
+
+func TestLoadLocation(t *testing.T) {
+	if loc, err := loadLocation(""); err != nil || loc != time.Local {
+		t.Errorf("Expected the system timezone by default, got %v, %v", loc, err)
+	}
+	if _, err := loadLocation("Mars/Olympus_Mons"); err == nil {
+		t.Error("Expected error for unknown timezone")
+	}
+}
+
+func TestHandleGetCalendarMergesSources(t *testing.T) {
+	svc := &Service{
+		calendarConfig: models.CalendarConfig{NumberOfWeeks: 1},
+		sources: []calendarSource{
+			{
+				config: models.CalendarEntry{CalendarID: "family", Color: "blue"},
+				source: &fakeSource{events: []models.CalendarEvent{{ID: "google-1", Summary: "Dentist"}}},
+			},
+			{
+				config: models.CalendarEntry{Type: "ics", URL: "https://example.com/school.ics", Color: "green"},
+				source: &fakeSource{events: []models.CalendarEvent{{ID: "ics-1", Summary: "Field trip"}}},
+			},
+		},
+		now: func() time.Time {
+			return time.Date(2024, 1, 10, 12, 0, 0, 0, time.Local)
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/calendar", nil)
+	w := httptest.NewRecorder()
+	svc.HandleGetCalendar(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	// Events without a timezone leave it out rather than sending it empty
+	if strings.Contains(w.Body.String(), `"timeZone"`) {
+		t.Errorf("Expected no timeZone fields, got %s", w.Body.String())
+	}
+
+	var response struct {
+		Version int                    `json:"version"`
+		Events  []models.CalendarEvent `json:"events"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.Version != models.CalendarAPIVersion {
+		t.Errorf("Expected contract version %d, got %d", models.CalendarAPIVersion, response.Version)
+	}
+	if len(response.Events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(response.Events))
+	}
+	if response.Events[0].ColorID != "blue" || response.Events[1].ColorID != "green" {
+		t.Errorf("Expected per-calendar colors blue and green, got %s and %s", response.Events[0].ColorID, response.Events[1].ColorID)
+	}
+}
+
+func TestHandleGetCalendarPartialFailure(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.Local)
+	svc := &Service{
+		calendarConfig: models.CalendarConfig{NumberOfWeeks: 1},
+		sources: []calendarSource{
+			{
+				config: models.CalendarEntry{CalendarID: "shared", Color: "fuchsia"},
+				source: &fakeSource{err: errors.New("googleapi: Error 404: Not Found, notFound")},
+			},
+			{
+				config: models.CalendarEntry{CalendarID: "family", Color: "blue"},
+				source: &fakeSource{events: []models.CalendarEvent{{ID: "google-1", Summary: "Dentist"}}},
+			},
+		},
+		now: func() time.Time { return now },
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/calendar", nil)
+	w := httptest.NewRecorder()
+	svc.HandleGetCalendar(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response struct {
+		Events         []models.CalendarEvent  `json:"events"`
+		CalendarStatus []models.CalendarStatus `json:"calendarStatus"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(response.Events) != 1 || response.Events[0].ID != "google-1" {
+		t.Errorf("Expected only the event of the working calendar, got %+v", response.Events)
+	}
+	if len(response.CalendarStatus) != 2 {
+		t.Fatalf("Expected 2 calendar statuses, got %d", len(response.CalendarStatus))
+	}
+
+	failed, ok := response.CalendarStatus[0], response.CalendarStatus[1]
+	if failed.Status != "error" || !strings.Contains(failed.Error, "notFound") || failed.LastSuccess != nil {
+		t.Errorf("Unexpected status for failing calendar: %+v", failed)
+	}
+	if ok.Status != "ok" || ok.LastSuccess == nil || !ok.LastSuccess.Equal(now) {
+		t.Errorf("Unexpected status for working calendar: %+v", ok)
+	}
+}
+
+// countingSource records how many fetches run at the same time
+type countingSource struct {
+	mu      *sync.Mutex
+	running *int
+	peak    *int
+}
+
+func (c *countingSource) Events(ctx context.Context, timeMin, timeMax time.Time) ([]models.CalendarEvent, error) {
+	c.mu.Lock()
+	*c.running++
+	if *c.running > *c.peak {
+		*c.peak = *c.running
+	}
+	c.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	c.mu.Lock()
+	*c.running--
+	c.mu.Unlock()
+	return nil, nil
+}
+
+func TestFetchAllBoundsConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+
+	svc := &Service{
+		calendarConfig: models.CalendarConfig{MaxConcurrentFetches: 2},
+		now:            time.Now,
+	}
+	for i := 0; i < 6; i++ {
+		svc.sources = append(svc.sources, calendarSource{
+			config: models.CalendarEntry{CalendarID: fmt.Sprintf("cal-%d", i)},
+			source: &countingSource{mu: &mu, running: &running, peak: &peak},
+		})
+	}
+
+	_, statuses := svc.fetchAll(context.Background(), time.Now(), time.Now().Add(time.Hour))
+
+	if len(statuses) != 6 {
+		t.Errorf("Expected 6 statuses, got %d", len(statuses))
+	}
+	if peak > 2 {
+		t.Errorf("Expected at most 2 concurrent fetches, got %d", peak)
+	}
+}
+
+func TestFetchAllServesStaleSnapshot(t *testing.T) {
+	store, err := snapshot.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create snapshot store: %v", err)
+	}
+
+	fetchedAt := time.Date(2024, 1, 10, 8, 0, 0, 0, time.UTC)
+	entry := models.CalendarEntry{CalendarID: "family", Color: "blue"}
+	online := &Service{
+		snapshots: store,
+		sources: []calendarSource{{
+			config: entry,
+			source: &fakeSource{events: []models.CalendarEvent{{ID: "google-1", Summary: "Dentist"}}},
+		}},
+		now: func() time.Time { return fetchedAt },
+	}
+	online.fetchAll(context.Background(), fetchedAt, fetchedAt.AddDate(0, 0, 7))
+
+	// A fresh service simulates a restart while the network is down
+	restarted := &Service{
+		snapshots: store,
+		sources: []calendarSource{{
+			config: entry,
+			source: &fakeSource{err: errors.New("dial tcp: i/o timeout")},
+		}},
+		now: func() time.Time { return fetchedAt.Add(2 * time.Hour) },
+	}
+	events, statuses := restarted.fetchAll(context.Background(), fetchedAt, fetchedAt.AddDate(0, 0, 7))
+
+	if len(events) != 1 || events[0].ID != "google-1" || events[0].ColorID != "blue" {
+		t.Errorf("Expected the snapshot event, got %+v", events)
+	}
+
+	status := statuses[0]
+	if status.Status != "error" || !status.Stale || status.AgeSeconds != 7200 {
+		t.Errorf("Expected a stale status aged 7200s, got %+v", status)
+	}
+	if status.LastSuccess == nil || !status.LastSuccess.Equal(fetchedAt) {
+		t.Errorf("Expected last success %v, got %v", fetchedAt, status.LastSuccess)
+	}
+}
+
+func TestHandleGetCalendarServesBackgroundSnapshot(t *testing.T) {
+	source := &fakeSource{events: []models.CalendarEvent{{ID: "google-1", Summary: "Dentist"}}}
+	fetchedAt := time.Date(2024, 1, 10, 8, 0, 0, 0, time.UTC)
+	svc := &Service{
+		calendarConfig: models.CalendarConfig{NumberOfWeeks: 1},
+		sources: []calendarSource{{
+			config: models.CalendarEntry{CalendarID: "family", Color: "blue"},
+			source: source,
+		}},
+		now: func() time.Time { return fetchedAt },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc.Start(ctx)
+
+	deadline := time.Now().Add(time.Second)
+	for svc.currentSnapshot() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Background refresh did not complete")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Changes upstream are not visible until the next refresh
+	source.events = []models.CalendarEvent{{ID: "google-2", Summary: "Piano"}}
+
+	w := httptest.NewRecorder()
+	svc.HandleGetCalendar(w, httptest.NewRequest(http.MethodGet, "/api/calendar", nil))
+
+	var response struct {
+		Events    []models.CalendarEvent `json:"events"`
+		FetchedAt string                 `json:"fetchedAt"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Events) != 1 || response.Events[0].ID != "google-1" {
+		t.Errorf("Expected the snapshot events, got %+v", response.Events)
+	}
+	if response.FetchedAt != fetchedAt.Format(time.RFC3339) {
+		t.Errorf("Expected fetchedAt %s, got %s", fetchedAt.Format(time.RFC3339), response.FetchedAt)
+	}
+}
+
+func TestFetchAllCapsEventsPerCalendar(t *testing.T) {
+	busy := []models.CalendarEvent{
+		{ID: "3", Summary: "Swim", Start: models.EventTime{DateTime: "2024-01-12T09:00:00Z"}},
+		{ID: "1", Summary: "Dentist", Start: models.EventTime{DateTime: "2024-01-08T09:00:00Z"}},
+		{ID: "4", Summary: "Game", Start: models.EventTime{DateTime: "2024-01-13T09:00:00Z"}},
+		{ID: "2", Summary: "Vacation", Start: models.EventTime{Date: "2024-01-09"}},
+	}
+	svc := &Service{
+		sources: []calendarSource{
+			{
+				config: models.CalendarEntry{CalendarID: "busy", Color: "blue", MaxEvents: 2},
+				source: &fakeSource{events: busy},
+			},
+			{
+				config: models.CalendarEntry{CalendarID: "quiet", Color: "green", MaxEvents: 2},
+				source: &fakeSource{events: []models.CalendarEvent{{ID: "5", Summary: "Piano"}}},
+			},
+		},
+		now: time.Now,
+	}
+
+	timeMin := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	events, statuses := svc.fetchAll(context.Background(), timeMin, timeMin.AddDate(0, 0, 7))
+
+	var ids []string
+	for _, ev := range events {
+		ids = append(ids, ev.ID)
+	}
+	if want := "1,2,5"; strings.Join(ids, ",") != want {
+		t.Errorf("Expected the earliest events of the capped calendar, got %s", strings.Join(ids, ","))
+	}
+
+	if !statuses[0].Truncated || statuses[0].HiddenEvents != 2 {
+		t.Errorf("Expected busy calendar truncated with 2 hidden events, got %+v", statuses[0])
+	}
+	if statuses[1].Truncated || statuses[1].HiddenEvents != 0 {
+		t.Errorf("Expected quiet calendar not truncated, got %+v", statuses[1])
+	}
+}
+
+func TestFetchAllAppliesColorSource(t *testing.T) {
+	events := []models.CalendarEvent{
+		{ID: "1", Summary: "Dentist", EventColor: "red"},
+		{ID: "2", Summary: "Piano"},
+	}
+
+	tests := []struct {
+		colorSource string
+		want        string
+	}{
+		{"", "blue,blue"},
+		{colorSourceCalendar, "blue,blue"},
+		{colorSourceEvent, "red,"},
+		{colorSourceEventOverridesCalendar, "red,blue"},
+	}
+
+	for _, tt := range tests {
+		svc := &Service{
+			sources: []calendarSource{{
+				config: models.CalendarEntry{CalendarID: "family", Color: "blue", ColorSource: tt.colorSource},
+				source: &fakeSource{events: events},
+			}},
+			now: time.Now,
+		}
+
+		got, _ := svc.fetchAll(context.Background(), time.Now(), time.Now().Add(time.Hour))
+
+		var colors []string
+		for _, ev := range got {
+			if ev.CalendarColor != "blue" {
+				t.Errorf("Expected calendar color blue on %s, got %q", ev.ID, ev.CalendarColor)
+			}
+			colors = append(colors, ev.ColorID)
+		}
+		if strings.Join(colors, ",") != tt.want {
+			t.Errorf("color_source %q: expected colors %s, got %s", tt.colorSource, tt.want, strings.Join(colors, ","))
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package calendarservice
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+// defaultCategoryRules classify events when the config has no categories.
+// Pickups are only recognised on calendars colored recycling, whose other
+// events are hidden.
+var defaultCategoryRules = []models.CategoryRule{
+	{Category: "recycling-cardboard", Icon: "cardboard-recycling", Summary: `(?i)^\s*cardboard\s*$`, Colors: []string{"recycling"}, AllDay: true, Badge: true},
+	{Category: "recycling-cans", Icon: "can-recycling", Summary: `(?i)^\s*cans\s*$`, Colors: []string{"recycling"}, AllDay: true, Badge: true},
+	{Category: "trash", Icon: "trash", Summary: `(?i)^\s*trash\s*$`, Colors: []string{"recycling"}, AllDay: true, Badge: true},
+	{Category: "recycling", Colors: []string{"recycling"}, Badge: true},
+	{Category: "birthday", Icon: "birthday", Keywords: []string{"birthday", "bday"}},
+	{Category: "sports", Icon: "sports", Keywords: []string{"soccer", "baseball", "softball", "basketball", "football", "hockey", "lacrosse", "swim", "tennis", "practice"}},
+	{Category: "school", Icon: "school", Keywords: []string{"school", "pta", "field trip", "teacher", "report card"}},
+	{Category: "doctor", Icon: "doctor", Keywords: []string{"doctor", "dentist", "pediatrician", "orthodontist", "checkup"}},
+}
+
+// eventClassifier assigns categories to events. A nil classifier leaves every
+// event unclassified.
+type eventClassifier struct {
+	rules []categoryRule
+}
+
+// categoryRule is a validated models.CategoryRule
+type categoryRule struct {
+	category  string
+	icon      string
+	keywords  *regexp.Regexp
+	summary   *regexp.Regexp
+	calendars map[string]bool
+	colors    map[string]bool
+	allDay    bool
+	badge     bool
+}
+
+// newEventClassifier compiles the category rules, falling back to the
+// built-in ones when rules is nil. It returns nil when there are no rules.
+func newEventClassifier(rules []models.CategoryRule) (*eventClassifier, error) {
+	if rules == nil {
+		rules = defaultCategoryRules
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	classifier := &eventClassifier{}
+	for i, r := range rules {
+		if r.Category == "" {
+			return nil, fmt.Errorf("category rule %d is missing a category", i+1)
+		}
+		if len(r.Keywords) == 0 && r.Summary == "" && len(r.Calendars) == 0 && len(r.Colors) == 0 {
+			return nil, fmt.Errorf("category %q needs keywords, a summary pattern, calendars or colors", r.Category)
+		}
+
+		rule := categoryRule{
+			category: r.Category,
+			icon:     r.Icon,
+			keywords: keywordPattern(r.Keywords),
+			allDay:   r.AllDay,
+			badge:    r.Badge,
+		}
+		if len(r.Calendars) > 0 {
+			rule.calendars = map[string]bool{}
+			for _, name := range r.Calendars {
+				rule.calendars[name] = true
+			}
+		}
+		if len(r.Colors) > 0 {
+			rule.colors = map[string]bool{}
+			for _, color := range r.Colors {
+				rule.colors[color] = true
+			}
+		}
+
+		var err error
+		if rule.summary, err = compileFilterPattern(r.Summary); err != nil {
+			return nil, fmt.Errorf("category %q has invalid summary pattern: %v", r.Category, err)
+		}
+
+		classifier.rules = append(classifier.rules, rule)
+	}
+
+	return classifier, nil
+}
+
+// keywordPattern matches any of the keywords as whole words, in any case
+func keywordPattern(keywords []string) *regexp.Regexp {
+	if len(keywords) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(keywords))
+	for i, keyword := range keywords {
+		quoted[i] = regexp.QuoteMeta(strings.TrimSpace(keyword))
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+}
+
+// classify sets the category of an event from calendar, the name of the
+// calendar it is on, and the calendar's color in its CalendarColor
+func (c *eventClassifier) classify(event *models.CalendarEvent, calendar string) {
+	if c == nil {
+		return
+	}
+
+	for _, rule := range c.rules {
+		if rule.matches(*event, calendar) {
+			event.Category = rule.category
+			event.Icon = rule.icon
+			event.Badge = rule.badge
+			return
+		}
+	}
+}
+
+func (r categoryRule) matches(event models.CalendarEvent, calendar string) bool {
+	if r.calendars != nil && !r.calendars[calendar] {
+		return false
+	}
+	if r.colors != nil && !r.colors[event.CalendarColor] {
+		return false
+	}
+	if r.allDay && event.Start.DateTime != "" {
+		return false
+	}
+	if r.keywords == nil && r.summary == nil {
+		return true
+	}
+	return (r.keywords != nil && r.keywords.MatchString(event.Summary)) ||
+		(r.summary != nil && r.summary.MatchString(event.Summary))
+}
@@ -0,0 +1,145 @@
+package calendarservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+func TestDefaultCategories(t *testing.T) {
+	classifier, err := newEventClassifier(nil)
+	if err != nil {
+		t.Fatalf("Failed to compile the built-in categories: %v", err)
+	}
+
+	allDay := models.EventTime{Date: "2024-01-10"}
+	timed := models.EventTime{DateTime: "2024-01-10T09:00:00Z"}
+	testCases := []struct {
+		summary  string
+		start    models.EventTime
+		color    string
+		category string
+		icon     string
+		badge    bool
+	}{
+		{"Cardboard", allDay, "recycling", "recycling-cardboard", "cardboard-recycling", true},
+		{"Cardboard", timed, "recycling", "recycling", "", true},
+		{"Cans", allDay, "recycling", "recycling-cans", "can-recycling", true},
+		{"Trash", allDay, "recycling", "trash", "trash", true},
+		{"Trash", timed, "recycling", "recycling", "", true},
+		{"Yard waste", allDay, "recycling", "recycling", "", true},
+		{"Trash", allDay, "blue", "", "", false},
+		{"Cans", allDay, "green", "", "", false},
+		{"Take out the trash", allDay, "blue", "", "", false},
+		{"Emma's Birthday party", allDay, "blue", "birthday", "birthday", false},
+		{"Soccer practice", timed, "blue", "sports", "sports", false},
+		{"No School", allDay, "blue", "school", "school", false},
+		{"Dentist", timed, "blue", "doctor", "doctor", false},
+		{"Swimmers meeting", timed, "blue", "", "", false},
+	}
+
+	for _, tc := range testCases {
+		event := models.CalendarEvent{Summary: tc.summary, Start: tc.start, CalendarColor: tc.color}
+		classifier.classify(&event, "family")
+		if event.Category != tc.category || event.Icon != tc.icon || event.Badge != tc.badge {
+			t.Errorf("%q on %s: expected %q/%q/%v, got %q/%q/%v", tc.summary, tc.color, tc.category, tc.icon, tc.badge, event.Category, event.Icon, event.Badge)
+		}
+	}
+}
+
+func TestConfiguredCategories(t *testing.T) {
+	classifier, err := newEventClassifier([]models.CategoryRule{
+		{Category: "work", Icon: "briefcase", Calendars: []string{"work@example.com"}},
+		{Category: "music", Icon: "note", Keywords: []string{"piano", "violin"}},
+		{Category: "sports", Icon: "sports", Summary: `(?i)\bgame\b`},
+	})
+	if err != nil {
+		t.Fatalf("Failed to compile categories: %v", err)
+	}
+
+	testCases := []struct {
+		summary  string
+		calendar string
+		category string
+	}{
+		{"Piano lesson", "work@example.com", "work"},
+		{"Piano lesson", "family", "music"},
+		{"VIOLIN recital", "family", "music"},
+		{"Pianos for sale", "family", ""},
+		{"Hockey game", "family", "sports"},
+		{"Cardboard", "family", ""},
+	}
+
+	for _, tc := range testCases {
+		event := models.CalendarEvent{Summary: tc.summary}
+		classifier.classify(&event, tc.calendar)
+		if event.Category != tc.category {
+			t.Errorf("%q on %s: expected category %q, got %q", tc.summary, tc.calendar, tc.category, event.Category)
+		}
+	}
+}
+
+func TestNewEventClassifierErrors(t *testing.T) {
+	if classifier, err := newEventClassifier([]models.CategoryRule{}); classifier != nil || err != nil {
+		t.Errorf("Expected no classifier for an empty rule list, got %v, %v", classifier, err)
+	}
+
+	invalid := [][]models.CategoryRule{
+		{{Icon: "note", Keywords: []string{"piano"}}},
+		{{Category: "music", Icon: "note"}},
+		{{Category: "music", Icon: "note", Summary: "(piano"}},
+	}
+	for _, rules := range invalid {
+		if _, err := newEventClassifier(rules); err == nil {
+			t.Errorf("Expected an error for %+v", rules)
+		}
+	}
+}
+
+func TestFetchAllClassifiesEvents(t *testing.T) {
+	classifier, err := newEventClassifier(nil)
+	if err != nil {
+		t.Fatalf("Failed to compile the built-in categories: %v", err)
+	}
+	svc := &Service{
+		classifier: classifier,
+		sources: []calendarSource{{
+			config: models.CalendarEntry{Type: sourceTypeSchedule, Color: "recycling"},
+			source: &fakeSource{events: []models.CalendarEvent{
+				{ID: "1", Summary: "Cardboard", Start: models.EventTime{Date: "2024-01-10"}},
+			}},
+		}},
+		now: time.Now,
+	}
+
+	events, _ := svc.fetchAll(context.Background(), time.Now(), time.Now().Add(time.Hour))
+
+	if len(events) != 1 || events[0].Category != "recycling-cardboard" || !events[0].Badge {
+		t.Errorf("Expected a cardboard recycling badge, got %+v", events)
+	}
+}
+
+func TestFetchAllKeepsPickupsOfOtherCalendars(t *testing.T) {
+	classifier, err := newEventClassifier(nil)
+	if err != nil {
+		t.Fatalf("Failed to compile the built-in categories: %v", err)
+	}
+	svc := &Service{
+		classifier: classifier,
+		sources: []calendarSource{{
+			config: models.CalendarEntry{CalendarID: "family", Color: "blue"},
+			source: &fakeSource{events: []models.CalendarEvent{
+				{ID: "1", Summary: "Trash", Start: models.EventTime{Date: "2024-01-10"}},
+			}},
+		}},
+		now: time.Now,
+	}
+
+	events, _ := svc.fetchAll(context.Background(), time.Now(), time.Now().Add(time.Hour))
+
+	if len(events) != 1 || events[0].Badge || events[0].Category != "" {
+		t.Errorf("Expected Trash on a family calendar to stay in the event list, got %+v", events)
+	}
+}
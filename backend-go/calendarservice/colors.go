@@ -0,0 +1,150 @@
+package calendarservice
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// Values accepted in CalendarEntry.ColorSource
+const (
+	colorSourceCalendar               = "calendar"
+	colorSourceEvent                  = "event"
+	colorSourceEventOverridesCalendar = "event_overrides_calendar"
+)
+
+// paletteColor is a color name understood by the frontend's color maps
+type paletteColor struct {
+	name    string
+	r, g, b int
+}
+
+// palette lists the frontend's colors with the RGB value they are drawn with
+var palette = []paletteColor{
+	{"blue", 0x3b, 0x82, 0xf6},
+	{"green", 0x22, 0xc5, 0x5e},
+	{"red", 0xef, 0x44, 0x44},
+	{"orange", 0xf9, 0x73, 0x16},
+	{"purple", 0xa8, 0x55, 0xf7},
+	{"yellow", 0xea, 0xb3, 0x08},
+	{"gray", 0x6b, 0x72, 0x80},
+	{"pink", 0xec, 0x48, 0x99},
+	{"lime", 0x84, 0xcc, 0x16},
+	{"indigo", 0x63, 0x66, 0xf1},
+	{"teal", 0x14, 0xb8, 0xa6},
+	{"cyan", 0x06, 0xb6, 0xd4},
+	{"fuchsia", 0xd9, 0x46, 0xef},
+	{"rose", 0xf4, 0x3f, 0x5e},
+	{"emerald", 0x10, 0xb9, 0x81},
+	{"violet", 0x8b, 0x5c, 0xf6},
+	{"sky", 0x0e, 0xa5, 0xe9},
+	{"amber", 0xf5, 0x9e, 0x0b},
+	{"black", 0x00, 0x00, 0x00},
+}
+
+// validateColorSource rejects unknown CalendarEntry.ColorSource values
+func validateColorSource(source string) error {
+	switch source {
+	case "", colorSourceCalendar, colorSourceEvent, colorSourceEventOverridesCalendar:
+		return nil
+	}
+	return fmt.Errorf("unknown color_source %q", source)
+}
+
+// nearestPaletteColor returns the palette color closest to a "#rrggbb" color
+func nearestPaletteColor(hex string) (string, bool) {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return "", false
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return "", false
+	}
+	r, g, b := int(rgb>>16), int(rgb>>8&0xff), int(rgb&0xff)
+
+	best, bestDistance := "", -1
+	for _, c := range palette {
+		distance := (r-c.r)*(r-c.r) + (g-c.g)*(g-c.g) + (b-c.b)*(b-c.b)
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = c.name, distance
+		}
+	}
+	return best, true
+}
+
+// applyColors records the calendar color on the event and picks the color it
+// is shown with according to the calendar's color source
+func applyColors(event *models.CalendarEvent, config models.CalendarEntry) {
+	event.CalendarColor = config.Color
+
+	switch config.ColorSource {
+	case colorSourceEvent:
+		event.ColorID = event.EventColor
+	case colorSourceEventOverridesCalendar:
+		event.ColorID = config.Color
+		if event.EventColor != "" {
+			event.ColorID = event.EventColor
+		}
+	default:
+		event.ColorID = config.Color
+	}
+}
+
+// googleColors resolves Google event colorIds into palette names with the
+// Colors API. The definitions are fetched once and shared by every Google
+// calendar.
+type googleColors struct {
+	calendarSvc *calendar.Service
+	retryConfig retry.Config
+
+	mu    sync.Mutex
+	names map[string]string // Palette name by event colorId
+}
+
+// eventColorNames returns the palette name of every Google event colorId, or
+// nil if the definitions can't be fetched
+func (c *googleColors) eventColorNames(ctx context.Context) map[string]string {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	names := c.names
+	c.mu.Unlock()
+	if names != nil {
+		return names
+	}
+
+	// The lock isn't held while fetching, so calendars don't wait on each
+	// other's retries; concurrent first fetches may each ask Google once
+	colors, err := retry.DoWithResult(ctx, c.retryConfig, func() (*calendar.Colors, error) {
+		return c.calendarSvc.Colors.Get().Context(ctx).Do()
+	})
+	if err != nil {
+		// Events are shown without their own colors until the next refresh
+		log.Printf("Failed to fetch Google calendar colors: %v", err)
+		return nil
+	}
+
+	names = map[string]string{}
+	for id, definition := range colors.Event {
+		if name, ok := nearestPaletteColor(definition.Background); ok {
+			names[id] = name
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.names == nil {
+		c.names = names
+	}
+	return c.names
+}
@@ -0,0 +1,138 @@
+package calendarservice
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+// Values accepted in CalendarConfig.DuplicatePolicy
+const (
+	duplicatePolicyFirst      = "first"
+	duplicatePolicyMultiColor = "multi_color"
+	duplicatePolicyKeepBoth   = "keep_both"
+)
+
+// validateDuplicatePolicy rejects unknown CalendarConfig.DuplicatePolicy
+// values
+func validateDuplicatePolicy(policy string) error {
+	switch policy {
+	case "", duplicatePolicyFirst, duplicatePolicyMultiColor, duplicatePolicyKeepBoth:
+		return nil
+	}
+	return fmt.Errorf("unknown duplicate_policy %q", policy)
+}
+
+// mergeDuplicates merges copies of the same event found on several calendars,
+// e.g. a meeting both parents are invited to. Copies share their iCalendar
+// UID and start, which also tells apart the instances of a recurring event.
+// events are in calendar configuration order, so the first copy comes from
+// the first calendar listed. Under every policy, the events left list all
+// the calendars owning a copy.
+func mergeDuplicates(events []models.CalendarEvent, policy string, loc *time.Location) []models.CalendarEvent {
+	events = uniqueIDs(events)
+
+	owners := map[string][]models.EventCalendar{} // By instance key
+	for _, event := range events {
+		if key, ok := instanceKey(event, loc); ok {
+			owners[key] = append(owners[key], event.Calendars...)
+		}
+	}
+
+	merged := make([]models.CalendarEvent, 0, len(events))
+	seen := map[string]int{} // Index in merged by instance key
+	for _, event := range events {
+		key, ok := instanceKey(event, loc)
+		if !ok {
+			merged = append(merged, event)
+			continue
+		}
+
+		i, dup := seen[key]
+		if !dup || policy == duplicatePolicyKeepBoth {
+			event.Calendars = append([]models.EventCalendar(nil), owners[key]...)
+			seen[key] = len(merged)
+			merged = append(merged, event)
+			continue
+		}
+
+		// The copy shown belongs to everyone either copy was tagged with
+		first := &merged[i]
+		first.People = mergePeople(first.People, event.People)
+
+		if policy == duplicatePolicyMultiColor {
+			if len(first.Colors) == 0 {
+				first.Colors = []string{first.ColorID}
+			}
+			if !containsString(first.Colors, event.ColorID) {
+				first.Colors = append(first.Colors, event.ColorID)
+			}
+		}
+	}
+	return merged
+}
+
+// uniqueIDs suffixes the ID of an event sharing it with an earlier one, like
+// a copy kept by keep_both or a pickup on two calendars, with the event's
+// calendar, and a counter when that is taken too. The display and the grid
+// lanes refer to events by ID. events is updated in place.
+func uniqueIDs(events []models.CalendarEvent) []models.CalendarEvent {
+	used := map[string]bool{}
+	for i := range events {
+		event := &events[i]
+		if used[event.ID] {
+			suffix := strconv.Itoa(i)
+			if len(event.Calendars) > 0 {
+				suffix = event.Calendars[0].Name
+			}
+			base := event.ID + "@" + suffix
+			event.ID = base
+			for n := 2; used[event.ID]; n++ {
+				event.ID = fmt.Sprintf("%s-%d", base, n)
+			}
+		}
+		used[event.ID] = true
+	}
+	return events
+}
+
+// instanceKey identifies an event instance across calendars. Events without
+// an iCalendar UID, like generated pickups, are never duplicates.
+func instanceKey(event models.CalendarEvent, loc *time.Location) (string, bool) {
+	if event.ICalUID == "" {
+		return "", false
+	}
+	start, _, ok := eventRange(event, loc)
+	if !ok {
+		return "", false
+	}
+	return event.ICalUID + "|" + start.UTC().Format(time.RFC3339), true
+}
+
+// mergePeople adds the people of other missing from people
+func mergePeople(people, other []models.PersonTag) []models.PersonTag {
+	for _, person := range other {
+		found := false
+		for _, p := range people {
+			if p.Name == person.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			people = append(people, person)
+		}
+	}
+	return people
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package calendarservice
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/snapshot"
+)
+
+func duplicateTestSources() []calendarSource {
+	return []calendarSource{
+		{
+			config: models.CalendarEntry{CalendarID: "family", Color: "blue"},
+			source: &fakeSource{events: []models.CalendarEvent{
+				{ID: "meeting", ICalUID: "meeting@example.com", Summary: "Teacher meeting", Start: models.EventTime{DateTime: "2024-01-10T10:00:00-05:00"}},
+				{ID: "piano_20240108", ICalUID: "piano@example.com", Summary: "Piano", Start: models.EventTime{DateTime: "2024-01-08T16:00:00-05:00"}},
+				{ID: "piano_20240115", ICalUID: "piano@example.com", Summary: "Piano", Start: models.EventTime{DateTime: "2024-01-15T16:00:00-05:00"}},
+				{ID: "pickup-trash-20240109", Summary: "Trash", Start: models.EventTime{Date: "2024-01-09"}},
+			}},
+		},
+		{
+			config: models.CalendarEntry{CalendarID: "parent", Color: "green"},
+			source: &fakeSource{events: []models.CalendarEvent{
+				{ID: "meeting-copy", ICalUID: "meeting@example.com", Summary: "Teacher meeting", Start: models.EventTime{DateTime: "2024-01-10T15:00:00Z"}},
+				{ID: "piano_20240108", ICalUID: "piano@example.com", Summary: "Piano", Start: models.EventTime{DateTime: "2024-01-08T16:00:00-05:00"}},
+				{ID: "pickup-trash-20240109", Summary: "Trash", Start: models.EventTime{Date: "2024-01-09"}},
+			}},
+		},
+	}
+}
+
+func describeEvents(events []models.CalendarEvent) string {
+	var described []string
+	for _, ev := range events {
+		colors := ev.ColorID
+		if len(ev.Colors) > 0 {
+			colors = strings.Join(ev.Colors, "+")
+		}
+		described = append(described, ev.ID+":"+colors)
+	}
+	return strings.Join(described, " ")
+}
+
+func TestMergeDuplicates(t *testing.T) {
+	testCases := []struct {
+		policy   string
+		expected string
+	}{
+		{
+			policy:   "",
+			expected: "meeting:blue piano_20240108:blue piano_20240115:blue pickup-trash-20240109:blue pickup-trash-20240109@parent:green",
+		},
+		{
+			policy:   "first",
+			expected: "meeting:blue piano_20240108:blue piano_20240115:blue pickup-trash-20240109:blue pickup-trash-20240109@parent:green",
+		},
+		{
+			policy:   "multi_color",
+			expected: "meeting:blue+green piano_20240108:blue+green piano_20240115:blue pickup-trash-20240109:blue pickup-trash-20240109@parent:green",
+		},
+		{
+			policy:   "keep_both",
+			expected: "meeting:blue piano_20240108:blue piano_20240115:blue pickup-trash-20240109:blue meeting-copy:green piano_20240108@parent:green pickup-trash-20240109@parent:green",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.policy, func(t *testing.T) {
+			svc := &Service{
+				calendarConfig: models.CalendarConfig{DuplicatePolicy: tc.policy},
+				sources:        duplicateTestSources(),
+				now:            time.Now,
+			}
+
+			timeMin := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+			events, _ := svc.fetchAll(context.Background(), timeMin, timeMin.AddDate(0, 0, 14))
+			if got := describeEvents(events); got != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestMergeDuplicatesListsCalendars(t *testing.T) {
+	for _, policy := range []string{"first", "multi_color", "keep_both"} {
+		t.Run(policy, func(t *testing.T) {
+			svc := &Service{
+				calendarConfig: models.CalendarConfig{DuplicatePolicy: policy},
+				sources:        duplicateTestSources(),
+				now:            time.Now,
+			}
+
+			timeMin := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+			events, _ := svc.fetchAll(context.Background(), timeMin, timeMin.AddDate(0, 0, 14))
+
+			calendars := map[string][]string{} // Described calendars of each copy by summary
+			for _, event := range events {
+				var described []string
+				for _, cal := range event.Calendars {
+					described = append(described, cal.Name+":"+cal.Color)
+				}
+				calendars[event.Summary] = append(calendars[event.Summary], strings.Join(described, "+"))
+			}
+
+			meeting := "family:blue+parent:green"
+			if policy == "keep_both" {
+				meeting += " " + meeting
+			}
+			if got := strings.Join(calendars["Teacher meeting"], " "); got != meeting {
+				t.Errorf("Expected the meeting on %s, got %s", meeting, got)
+			}
+			if got := strings.Join(calendars["Trash"], " "); got != "family:blue parent:green" {
+				t.Errorf("Expected each pickup on its own calendar, got %s", got)
+			}
+		})
+	}
+}
+
+func TestMergeDuplicatesKeepsIDsUnique(t *testing.T) {
+	svc := &Service{
+		calendarConfig: models.CalendarConfig{DuplicatePolicy: "keep_both"},
+		sources: []calendarSource{{
+			config: models.CalendarEntry{CalendarID: "parent", Color: "green"},
+			source: &fakeSource{events: []models.CalendarEvent{
+				{ID: "trip", Summary: "Trip", Start: models.EventTime{Date: "2024-01-09"}},
+				{ID: "trip", Summary: "Trip", Start: models.EventTime{Date: "2024-01-10"}},
+				{ID: "trip", Summary: "Trip", Start: models.EventTime{Date: "2024-01-11"}},
+			}},
+		}},
+		now: time.Now,
+	}
+
+	timeMin := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	events, _ := svc.fetchAll(context.Background(), timeMin, timeMin.AddDate(0, 0, 14))
+	if got, want := describeEvents(events), "trip:green trip@parent:green trip@parent-2:green"; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestMergeDuplicatesWithStaleSnapshot(t *testing.T) {
+	store, err := snapshot.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create snapshot store: %v", err)
+	}
+
+	svc := &Service{
+		calendarConfig: models.CalendarConfig{DuplicatePolicy: "multi_color"},
+		snapshots:      store,
+		sources:        duplicateTestSources(),
+		now:            time.Now,
+	}
+	timeMin := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	svc.fetchAll(context.Background(), timeMin, timeMin.AddDate(0, 0, 14))
+
+	// The second calendar is now served from its snapshot
+	svc.sources[1].source = &fakeSource{err: errors.New("dial tcp: i/o timeout")}
+	events, statuses := svc.fetchAll(context.Background(), timeMin, timeMin.AddDate(0, 0, 14))
+
+	if !statuses[1].Stale {
+		t.Fatalf("Expected the second calendar to be stale, got %+v", statuses[1])
+	}
+	if got := describeEvents(events); !strings.HasPrefix(got, "meeting:blue+green piano_20240108:blue+green ") {
+		t.Errorf("Expected stale copies to be merged, got %s", got)
+	}
+}
+
+func TestValidateDuplicatePolicy(t *testing.T) {
+	if err := validateDuplicatePolicy("multi_color"); err != nil {
+		t.Errorf("Expected multi_color to be valid, got %v", err)
+	}
+	if err := validateDuplicatePolicy("merge"); err == nil {
+		t.Error("Expected error for unknown duplicate policy")
+	}
+}
@@ -0,0 +1,51 @@
+package calendarservice
+
+import (
+	"sort"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+// eventRange returns the start and end of an event. All-day dates are
+// interpreted in loc.
+func eventRange(event models.CalendarEvent, loc *time.Location) (time.Time, time.Time, bool) {
+	start, ok := parseEventTime(event.Start, loc)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	end, ok := parseEventTime(event.End, loc)
+	if !ok || !end.After(start) {
+		// Zero-length events still occupy their start instant
+		end = start.Add(time.Nanosecond)
+	}
+	return start, end, true
+}
+
+func parseEventTime(t models.EventTime, loc *time.Location) (time.Time, bool) {
+	if t.DateTime != "" {
+		parsed, err := time.Parse(time.RFC3339, t.DateTime)
+		return parsed, err == nil
+	}
+	parsed, err := time.ParseInLocation(scheduleDateLayout, t.Date, loc)
+	return parsed, err == nil
+}
+
+// eventOverlaps reports whether the event intersects [timeMin, timeMax)
+func eventOverlaps(event models.CalendarEvent, timeMin, timeMax time.Time) bool {
+	start, end, ok := eventRange(event, timeMin.Location())
+	return ok && start.Before(timeMax) && end.After(timeMin)
+}
+
+// sortEventsByStart orders events by start time, then by ID so the order is
+// stable across fetches
+func sortEventsByStart(events []models.CalendarEvent, loc *time.Location) {
+	sort.SliceStable(events, func(i, j int) bool {
+		a, _, _ := eventRange(events[i], loc)
+		b, _, _ := eventRange(events[j], loc)
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return events[i].ID < events[j].ID
+	})
+}
@@ -0,0 +1,208 @@
+package calendarservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+// Default number of calendars fetched at the same time
+const defaultMaxConcurrentFetches = 4
+
+// Values of models.CalendarStatus.Status
+const (
+	calendarStatusOK    = "ok"
+	calendarStatusError = "error"
+)
+
+// fetchResult is the outcome of fetching one calendar
+type fetchResult struct {
+	events []models.CalendarEvent
+	err    error
+}
+
+// fetchAll fetches every configured calendar concurrently with a bounded
+// worker pool. A failing calendar doesn't fail the others: the events that
+// could be fetched are returned, in configuration order, along with the
+// status of every calendar.
+func (s *Service) fetchAll(ctx context.Context, timeMin, timeMax time.Time) ([]models.CalendarEvent, []models.CalendarStatus) {
+	results := make([]fetchResult, len(s.sources))
+
+	workers := s.calendarConfig.MaxConcurrentFetches
+	if workers <= 0 {
+		workers = defaultMaxConcurrentFetches
+	}
+	if workers > len(s.sources) {
+		workers = len(s.sources)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				events, err := s.sources[i].source.Events(ctx, timeMin, timeMax)
+				cal := s.sources[i]
+				events = cal.filter.apply(events)
+
+				// People are matched by the addresses that title privacy
+				// strips, while busy blocks reveal nothing but their calendar
+				if cal.config.Privacy != privacyBusy {
+					s.tagPeople(events, cal.name())
+				}
+				events = applyPrivacy(events, cal.config.Privacy)
+				if cal.config.Privacy == privacyBusy {
+					s.tagPeople(events, cal.name())
+				}
+				results[i] = fetchResult{events: events, err: err}
+			}
+		}()
+	}
+	for i := range s.sources {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var events []models.CalendarEvent
+	statuses := make([]models.CalendarStatus, len(s.sources))
+	for i, cal := range s.sources {
+		result := results[i]
+		statuses[i] = models.CalendarStatus{
+			Calendar: cal.name(),
+			Color:    cal.config.Color,
+			Status:   calendarStatusOK,
+		}
+
+		calEvents := result.events
+		if result.err != nil {
+			log.Printf("Calendar API error for %s: %v", cal.name(), result.err)
+			statuses[i].Status = calendarStatusError
+			statuses[i].Error = result.err.Error()
+			calEvents = s.loadStaleEvents(cal, &statuses[i])
+		} else {
+			s.recordSuccess(cal.name(), s.now())
+			s.saveSnapshot(cal, result.events)
+		}
+
+		if max := cal.config.MaxEvents; max > 0 && len(calEvents) > max {
+			statuses[i].Truncated = true
+			statuses[i].HiddenEvents = len(calEvents) - max
+			calEvents = earliestEvents(calEvents, max, timeMin.Location())
+		}
+
+		for _, event := range calEvents {
+			event.Calendars = []models.EventCalendar{{Name: cal.name(), Color: cal.config.Color}}
+			applyColors(&event, cal.config)
+			s.classifier.classify(&event, cal.name())
+			events = append(events, event)
+		}
+
+		if last, ok := s.lastSuccessOf(cal.name()); ok {
+			statuses[i].LastSuccess = &last
+		}
+	}
+
+	return mergeDuplicates(events, s.calendarConfig.DuplicatePolicy, timeMin.Location()), statuses
+}
+
+func (s *Service) tagPeople(events []models.CalendarEvent, calendar string) {
+	for i := range events {
+		s.people.tag(&events[i], calendar)
+	}
+}
+
+// earliestEvents returns the first n events by start time, in that order
+func earliestEvents(events []models.CalendarEvent, n int, loc *time.Location) []models.CalendarEvent {
+	sorted := make([]models.CalendarEvent, len(events))
+	copy(sorted, events)
+	sortEventsByStart(sorted, loc)
+	return sorted[:n]
+}
+
+func (s *Service) recordSuccess(name string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lastSuccess == nil {
+		s.lastSuccess = map[string]time.Time{}
+	}
+	s.lastSuccess[name] = at
+}
+
+func (s *Service) lastSuccessOf(name string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last, ok := s.lastSuccess[name]
+	return last, ok
+}
+
+// snapshotName returns the name of the last-known-good snapshot of a calendar
+func snapshotName(cal calendarSource) string {
+	sum := sha256.Sum256([]byte(cal.name()))
+	return "calendar-" + hex.EncodeToString(sum[:8])
+}
+
+// snapshotEvent is how events are stored in snapshots. Unlike the JSON
+// contract it keeps the iCalendar UID, so stale events are merged with their
+// duplicates like fresh ones. Stale events keep the people they were tagged
+// with when fetched.
+type snapshotEvent struct {
+	models.CalendarEvent
+	ICalUID string `json:"iCalUID,omitempty"`
+}
+
+func (s *Service) saveSnapshot(cal calendarSource, events []models.CalendarEvent) {
+	if s.snapshots == nil {
+		return
+	}
+
+	stored := make([]snapshotEvent, len(events))
+	for i, event := range events {
+		stored[i] = snapshotEvent{CalendarEvent: event, ICalUID: event.ICalUID}
+	}
+	if err := s.snapshots.Save(snapshotName(cal), stored, s.now()); err != nil {
+		log.Printf("Failed to save snapshot for %s: %v", cal.name(), err)
+	}
+}
+
+// loadStaleEvents returns the last-known-good events of a calendar whose fetch
+// failed and marks its status as stale
+func (s *Service) loadStaleEvents(cal calendarSource, status *models.CalendarStatus) []models.CalendarEvent {
+	if s.snapshots == nil {
+		return nil
+	}
+
+	var stored []snapshotEvent
+	fetchedAt, err := s.snapshots.Load(snapshotName(cal), &stored)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to load snapshot for %s: %v", cal.name(), err)
+		}
+		return nil
+	}
+
+	if _, ok := s.lastSuccessOf(cal.name()); !ok {
+		// The snapshot survives restarts, unlike the in-memory record
+		s.recordSuccess(cal.name(), fetchedAt)
+	}
+	status.Stale = true
+	status.AgeSeconds = int64(s.now().Sub(fetchedAt).Seconds())
+
+	events := make([]models.CalendarEvent, len(stored))
+	for i, event := range stored {
+		events[i] = event.CalendarEvent
+		events[i].ICalUID = event.ICalUID
+	}
+	return events
+}
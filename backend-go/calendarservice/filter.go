@@ -0,0 +1,138 @@
+package calendarservice
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+// Values accepted in FilterRule.Action
+const (
+	filterActionExclude = "exclude"
+	filterActionInclude = "include"
+)
+
+// eventFilter decides which events of a calendar are shown. A nil filter shows
+// every event.
+type eventFilter struct {
+	include []filterRule
+	exclude []filterRule
+}
+
+// filterRule is a validated models.FilterRule
+type filterRule struct {
+	summary        *regexp.Regexp
+	description    *regexp.Regexp
+	responseStatus map[string]bool
+	transparency   map[string]bool
+	eventType      map[string]bool
+	visibility     map[string]bool
+}
+
+// newEventFilter compiles the filter rules of a calendar, or returns nil when
+// it has none
+func newEventFilter(rules []models.FilterRule) (*eventFilter, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	filter := &eventFilter{}
+	for i, r := range rules {
+		rule := filterRule{
+			responseStatus: valueSet(r.ResponseStatus),
+			transparency:   valueSet(r.Transparency),
+			eventType:      valueSet(r.EventType),
+			visibility:     valueSet(r.Visibility),
+		}
+
+		var err error
+		if rule.summary, err = compileFilterPattern(r.Summary); err != nil {
+			return nil, fmt.Errorf("filter %d has invalid summary pattern: %v", i+1, err)
+		}
+		if rule.description, err = compileFilterPattern(r.Description); err != nil {
+			return nil, fmt.Errorf("filter %d has invalid description pattern: %v", i+1, err)
+		}
+
+		switch strings.ToLower(r.Action) {
+		case "", filterActionExclude:
+			filter.exclude = append(filter.exclude, rule)
+		case filterActionInclude:
+			filter.include = append(filter.include, rule)
+		default:
+			return nil, fmt.Errorf("filter %d has unknown action %q", i+1, r.Action)
+		}
+	}
+
+	return filter, nil
+}
+
+func compileFilterPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+// valueSet lowercases values so they match case-insensitively
+func valueSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := map[string]bool{}
+	for _, v := range values {
+		set[strings.ToLower(v)] = true
+	}
+	return set
+}
+
+// apply returns the events the filter shows
+func (f *eventFilter) apply(events []models.CalendarEvent) []models.CalendarEvent {
+	if f == nil {
+		return events
+	}
+
+	var shown []models.CalendarEvent
+	for _, event := range events {
+		if f.shows(event) {
+			shown = append(shown, event)
+		}
+	}
+	return shown
+}
+
+func (f *eventFilter) shows(event models.CalendarEvent) bool {
+	for _, rule := range f.exclude {
+		if rule.matches(event) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, rule := range f.include {
+		if rule.matches(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the event meets every condition set in the rule
+func (r filterRule) matches(event models.CalendarEvent) bool {
+	if r.summary != nil && !r.summary.MatchString(event.Summary) {
+		return false
+	}
+	if r.description != nil && !r.description.MatchString(event.Description) {
+		return false
+	}
+	return matchesValue(r.responseStatus, event.ResponseStatus) &&
+		matchesValue(r.transparency, event.Transparency) &&
+		matchesValue(r.eventType, event.EventType) &&
+		matchesValue(r.visibility, event.Visibility)
+}
+
+func matchesValue(set map[string]bool, value string) bool {
+	return set == nil || set[strings.ToLower(value)]
+}
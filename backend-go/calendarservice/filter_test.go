@@ -0,0 +1,109 @@
+package calendarservice
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+func TestEventFilter(t *testing.T) {
+	events := []models.CalendarEvent{
+		googleEvent(&calendar.Event{Id: "standup", Summary: "Standup"}, nil),
+		googleEvent(&calendar.Event{Id: "declined", Summary: "Team lunch", Attendees: []*calendar.EventAttendee{
+			{Email: "someone@example.com", ResponseStatus: "accepted"},
+			{Email: "me@example.com", Self: true, ResponseStatus: "declined"},
+		}}, nil),
+		googleEvent(&calendar.Event{Id: "office", Summary: "Office", EventType: "workingLocation"}, nil),
+		googleEvent(&calendar.Event{Id: "focus", Summary: "Focus time", Transparency: "transparent"}, nil),
+		googleEvent(&calendar.Event{Id: "private", Summary: "Doctor", Visibility: "private"}, nil),
+		googleEvent(&calendar.Event{Id: "hold", Summary: "HOLD: offsite", Description: "tentative"}, nil),
+	}
+
+	testCases := []struct {
+		name     string
+		rules    []models.FilterRule
+		expected string
+	}{
+		{
+			name:     "No rules",
+			expected: "standup,declined,office,focus,private,hold",
+		},
+		{
+			name:     "Declined invitations",
+			rules:    []models.FilterRule{{ResponseStatus: []string{"declined"}}},
+			expected: "standup,office,focus,private,hold",
+		},
+		{
+			name:     "Event type and transparency",
+			rules:    []models.FilterRule{{EventType: []string{"workingLocation"}}, {Transparency: []string{"Transparent"}}},
+			expected: "standup,declined,private,hold",
+		},
+		{
+			name:     "Summary pattern",
+			rules:    []models.FilterRule{{Action: "exclude", Summary: "(?i)^hold:"}},
+			expected: "standup,declined,office,focus,private",
+		},
+		{
+			name:     "All conditions of a rule must match",
+			rules:    []models.FilterRule{{Summary: "^HOLD", Description: "confirmed"}},
+			expected: "standup,declined,office,focus,private,hold",
+		},
+		{
+			name: "Include and exclude rules",
+			rules: []models.FilterRule{
+				{Action: "include", Visibility: []string{"default"}},
+				{Summary: "Standup"},
+			},
+			expected: "declined,office,focus,hold",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filter, err := newEventFilter(tc.rules)
+			if err != nil {
+				t.Fatalf("Failed to create filter: %v", err)
+			}
+
+			var ids []string
+			for _, ev := range filter.apply(events) {
+				ids = append(ids, ev.ID)
+			}
+			if got := strings.Join(ids, ","); got != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestEventFilterInvalidRules(t *testing.T) {
+	if _, err := newEventFilter([]models.FilterRule{{Summary: "(unclosed"}}); err == nil {
+		t.Error("Expected error for invalid summary pattern")
+	}
+	if _, err := newEventFilter([]models.FilterRule{{Action: "hide"}}); err == nil {
+		t.Error("Expected error for unknown action")
+	}
+}
+
+func TestICSEventFilterAttributes(t *testing.T) {
+	cal, err := parseICS(strings.NewReader("BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:free@test\r\n" +
+		"SUMMARY:Free\r\n" +
+		"TRANSP:TRANSPARENT\r\n" +
+		"CLASS:PRIVATE\r\n" +
+		"DTSTART;VALUE=DATE:20240110\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse ics: %v", err)
+	}
+
+	event := parseICSEvents(cal, time.UTC)[0].toCalendarEvent()
+	if event.Transparency != "transparent" || event.Visibility != "private" || event.EventType != "default" {
+		t.Errorf("Expected transparent private default event, got %+v", event)
+	}
+}
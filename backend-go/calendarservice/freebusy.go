@@ -0,0 +1,55 @@
+package calendarservice
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// googleFreeBusySource shows a busy-only Google calendar. It asks the
+// Freebusy API when the calendar is busy, so no event details are ever
+// fetched.
+type googleFreeBusySource struct {
+	calendarSvc *calendar.Service
+	calendarID  string
+	retryConfig retry.Config
+}
+
+func (g *googleFreeBusySource) Events(ctx context.Context, timeMin, timeMax time.Time) ([]models.CalendarEvent, error) {
+	result, err := retry.DoWithResult(ctx, g.retryConfig, func() (*calendar.FreeBusyResponse, error) {
+		return g.calendarSvc.Freebusy.Query(&calendar.FreeBusyRequest{
+			TimeMin: timeMin.Format(time.RFC3339),
+			TimeMax: timeMax.Format(time.RFC3339),
+			Items:   []*calendar.FreeBusyRequestItem{{Id: g.calendarID}},
+		}).Context(ctx).Do()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	busy, ok := result.Calendars[g.calendarID]
+	if !ok {
+		return nil, fmt.Errorf("free/busy response has no calendar %s", g.calendarID)
+	}
+	if len(busy.Errors) > 0 {
+		reasons := make([]string, len(busy.Errors))
+		for i, e := range busy.Errors {
+			reasons[i] = e.Reason
+		}
+		return nil, fmt.Errorf("free/busy query failed: %s", strings.Join(reasons, ", "))
+	}
+
+	events := make([]models.CalendarEvent, 0, len(busy.Busy))
+	for _, period := range busy.Busy {
+		events = append(events, busyEvent(g.calendarID+"|"+period.Start+"|"+period.End,
+			models.EventTime{DateTime: period.Start},
+			models.EventTime{DateTime: period.End},
+		))
+	}
+	return events, nil
+}
@@ -0,0 +1,49 @@
+package calendarservice
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+func TestGoogleFreeBusySource(t *testing.T) {
+	fake := &fakeGoogleCalendar{
+		busy: []*calendar.TimePeriod{
+			{Start: "2024-01-09T14:00:00Z", End: "2024-01-09T15:00:00Z"},
+			{Start: "2024-01-10T09:00:00Z", End: "2024-01-10T12:00:00Z"},
+		},
+	}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	google := newTestGoogleSource(t, ts, nil)
+	src := &googleFreeBusySource{calendarSvc: google.calendarSvc, calendarID: "family", retryConfig: google.retryConfig}
+
+	timeMin := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	events, err := src.Events(context.Background(), timeMin, timeMin.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatalf("Failed to query free/busy: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 busy blocks, got %d", len(events))
+	}
+	if events[0].Summary != busySummary || events[0].Start.DateTime != "2024-01-09T14:00:00Z" || events[0].End.DateTime != "2024-01-09T15:00:00Z" {
+		t.Errorf("Expected a busy block from 14:00 to 15:00, got %+v", events[0])
+	}
+	if events[0].ID == events[1].ID {
+		t.Errorf("Expected distinct IDs, got %s twice", events[0].ID)
+	}
+	if fake.fullSyncs != 0 {
+		t.Errorf("Expected no event listing, got %d", fake.fullSyncs)
+	}
+
+	// A calendar the credentials can't see is reported as an error
+	src.calendarID = "secret"
+	if _, err := src.Events(context.Background(), timeMin, timeMin.AddDate(0, 0, 7)); err == nil {
+		t.Error("Expected an error for a calendar free/busy can't read")
+	}
+}
@@ -0,0 +1,281 @@
+package calendarservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+	"github.com/coreyk/piinky/backend-go/snapshot"
+	calendar "google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// A full sync also fetches this far past the display window, so the window can
+// move forward for a while before another full sync is needed
+const googleSyncLookahead = 28 * 24 * time.Hour
+
+// googleSource fetches events from the Google Calendar API. After an initial
+// full sync it keeps a local copy of the calendar and only asks Google for the
+// changes since the last sync token.
+type googleSource struct {
+	calendarSvc *calendar.Service
+	calendarID  string
+	retryConfig retry.Config
+	snapshots   *snapshot.Store // Persists the sync state, may be nil
+	colors      *googleColors   // Resolves event colors, may be nil
+	now         func() time.Time
+
+	mu    sync.Mutex
+	state *googleSyncState
+}
+
+// googleSyncState is the local copy of a Google calendar
+type googleSyncState struct {
+	SyncToken string                     `json:"syncToken"`
+	TimeMin   time.Time                  `json:"timeMin"` // Range covered by the full sync
+	TimeMax   time.Time                  `json:"timeMax"`
+	Events    map[string]*calendar.Event `json:"events"` // By event ID
+}
+
+// eventsListParams selects what an Events.List request returns: the events of
+// [timeMin, timeMax) for a full sync, or the changes since syncToken
+type eventsListParams struct {
+	timeMin   string
+	timeMax   string
+	syncToken string
+}
+
+func (g *googleSource) Events(ctx context.Context, timeMin, timeMax time.Time) ([]models.CalendarEvent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.state == nil {
+		g.state = g.loadState()
+	}
+
+	// The saved state is only rewritten when the sync changed it, sparing the
+	// SD card a write on every poll of a quiet calendar
+	changed := true
+	if g.state == nil || !g.state.covers(timeMin, timeMax) {
+		if err := g.fullSync(ctx, timeMin, timeMax.Add(googleSyncLookahead)); err != nil {
+			return nil, err
+		}
+	} else if updated, err := g.incrementalSync(ctx); err != nil {
+		if !isSyncTokenExpired(err) {
+			return nil, err
+		}
+		log.Printf("Sync token of %s expired, doing a full sync", g.calendarID)
+		if err := g.fullSync(ctx, timeMin, timeMax.Add(googleSyncLookahead)); err != nil {
+			return nil, err
+		}
+	} else {
+		changed = updated
+	}
+
+	if changed {
+		g.saveState()
+	}
+	return g.state.between(timeMin, timeMax, g.colors.eventColorNames(ctx)), nil
+}
+
+// fullSync replaces the local copy with every event of [timeMin, timeMax)
+func (g *googleSource) fullSync(ctx context.Context, timeMin, timeMax time.Time) error {
+	result, err := fetchCalendarEventsWithRetry(ctx, g.calendarSvc, g.retryConfig, g.calendarID, eventsListParams{
+		timeMin: timeMin.Format(time.RFC3339),
+		timeMax: timeMax.Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	state := &googleSyncState{
+		SyncToken: result.NextSyncToken,
+		TimeMin:   timeMin,
+		TimeMax:   timeMax,
+		Events:    map[string]*calendar.Event{},
+	}
+	state.apply(result.Items)
+	g.state = state
+	return nil
+}
+
+// incrementalSync applies the changes made since the last sync and reports
+// whether there were any. Without changes the new sync token is kept in memory
+// only; the saved one still leads to the same state.
+func (g *googleSource) incrementalSync(ctx context.Context) (bool, error) {
+	result, err := fetchCalendarEventsWithRetry(ctx, g.calendarSvc, g.retryConfig, g.calendarID, eventsListParams{syncToken: g.state.SyncToken})
+	if err != nil {
+		return false, err
+	}
+
+	g.state.apply(result.Items)
+	g.state.SyncToken = result.NextSyncToken
+	return len(result.Items) > 0, nil
+}
+
+// fetchCalendarEventsWithRetry reads every page of an Events.List request.
+// The returned Events holds the items of all pages and the token for the next
+// incremental sync.
+func fetchCalendarEventsWithRetry(ctx context.Context, calendarSvc *calendar.Service, cfg retry.Config, calendarID string, params eventsListParams) (*calendar.Events, error) {
+	all := &calendar.Events{}
+	pageToken := ""
+	for {
+		page, err := retry.DoWithResult(ctx, cfg, func() (*calendar.Events, error) {
+			call := calendarSvc.Events.List(calendarID).
+				Context(ctx).
+				SingleEvents(true)
+			if params.syncToken != "" {
+				// Google rejects time bounds and ordering on incremental syncs
+				call = call.SyncToken(params.syncToken)
+			} else {
+				call = call.TimeMin(params.timeMin).TimeMax(params.timeMax)
+			}
+			if pageToken != "" {
+				call = call.PageToken(pageToken)
+			}
+			return call.Do()
+		})
+		if err != nil {
+			return nil, err
+		}
+		all.Items = append(all.Items, page.Items...)
+
+		if page.NextPageToken == "" {
+			all.NextSyncToken = page.NextSyncToken
+			return all, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// isSyncTokenExpired reports whether Google invalidated the sync token and a
+// full sync is required
+func isSyncTokenExpired(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusGone
+}
+
+// covers reports whether the local copy holds the events of [timeMin, timeMax)
+func (s *googleSyncState) covers(timeMin, timeMax time.Time) bool {
+	return s.SyncToken != "" && !timeMin.Before(s.TimeMin) && !timeMax.After(s.TimeMax)
+}
+
+// apply adds or updates events and removes the cancelled ones
+func (s *googleSyncState) apply(items []*calendar.Event) {
+	for _, event := range items {
+		if event.Status == "cancelled" {
+			delete(s.Events, event.Id)
+			continue
+		}
+		s.Events[event.Id] = event
+	}
+}
+
+// between returns the events overlapping [timeMin, timeMax) ordered by start
+// time. colorNames maps event colorIds to palette names.
+func (s *googleSyncState) between(timeMin, timeMax time.Time, colorNames map[string]string) []models.CalendarEvent {
+	var events []models.CalendarEvent
+	for _, item := range s.Events {
+		event := googleEvent(item, colorNames)
+		if eventOverlaps(event, timeMin, timeMax) {
+			events = append(events, event)
+		}
+	}
+
+	sortEventsByStart(events, timeMin.Location())
+	return events
+}
+
+// googleEvent maps a Google Calendar event into the source-independent model
+func googleEvent(item *calendar.Event, colorNames map[string]string) models.CalendarEvent {
+	event := models.CalendarEvent{
+		ID:          item.Id,
+		Summary:     item.Summary,
+		Description: item.Description,
+		Location:    item.Location,
+		EventColor:  colorNames[item.ColorId],
+		ICalUID:     item.ICalUID,
+	}
+	if item.Start != nil {
+		event.Start = models.EventTime{Date: item.Start.Date, DateTime: item.Start.DateTime, TimeZone: item.Start.TimeZone}
+	}
+	if item.End != nil {
+		event.End = models.EventTime{Date: item.End.Date, DateTime: item.End.DateTime, TimeZone: item.End.TimeZone}
+	}
+
+	// Google leaves out fields that have their default value
+	event.Transparency = defaultString(item.Transparency, "opaque")
+	event.Visibility = defaultString(item.Visibility, "default")
+	event.EventType = defaultString(item.EventType, "default")
+	for _, attendee := range item.Attendees {
+		if attendee.Self {
+			event.ResponseStatus = attendee.ResponseStatus
+		}
+		if attendee.Email != "" {
+			event.Attendees = append(event.Attendees, attendee.Email)
+		}
+	}
+	if item.Organizer != nil {
+		event.Organizer = item.Organizer.Email
+	}
+	return event
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// syncStateName returns the name of the persisted sync state of a calendar
+func (g *googleSource) syncStateName() string {
+	sum := sha256.Sum256([]byte(g.calendarID))
+	return "google-sync-" + hex.EncodeToString(sum[:8])
+}
+
+func (g *googleSource) loadState() *googleSyncState {
+	if g.snapshots == nil {
+		return nil
+	}
+
+	var state googleSyncState
+	if _, err := g.snapshots.Load(g.syncStateName(), &state); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to load sync state for %s: %v", g.calendarID, err)
+		}
+		return nil
+	}
+	if state.Events == nil {
+		state.Events = map[string]*calendar.Event{}
+	}
+	return &state
+}
+
+// deleteSavedState removes sync state saved while the calendar's privacy
+// still allowed it
+func (g *googleSource) deleteSavedState(snapshots *snapshot.Store) {
+	if snapshots == nil {
+		return
+	}
+	if err := snapshots.Delete(g.syncStateName()); err != nil {
+		log.Printf("Failed to delete sync state for %s: %v", g.calendarID, err)
+	}
+}
+
+func (g *googleSource) saveState() {
+	if g.snapshots == nil {
+		return
+	}
+	if err := g.snapshots.Save(g.syncStateName(), g.state, g.now()); err != nil {
+		log.Printf("Failed to save sync state for %s: %v", g.calendarID, err)
+	}
+}
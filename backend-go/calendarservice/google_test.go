@@ -0,0 +1,482 @@
+package calendarservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+	"github.com/coreyk/piinky/backend-go/snapshot"
+	calendar "google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// fakeGoogleCalendar serves Events.List for a single calendar, paging full
+// syncs and answering incremental syncs from the deltas of each sync token
+type fakeGoogleCalendar struct {
+	fullSync    [][]*calendar.Event          // Pages of a full sync
+	deltas      map[string][]*calendar.Event // Changes by sync token
+	expired     map[string]bool              // Sync tokens answered with 410 Gone
+	eventColors map[string]string            // Event color backgrounds by colorId
+	busy        []*calendar.TimePeriod       // Answer to free/busy queries
+	nextToken   string
+	fullSyncs   int
+	deltaSyncs  int
+	colorGets   int
+}
+
+func (f *fakeGoogleCalendar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if strings.HasSuffix(r.URL.Path, "/colors") {
+		f.colorGets++
+		colors := calendar.Colors{Event: map[string]calendar.ColorDefinition{}}
+		for id, background := range f.eventColors {
+			colors.Event[id] = calendar.ColorDefinition{Background: background, Foreground: "#1d1d1d"}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(colors)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/freeBusy") {
+		var req calendar.FreeBusyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.Method != http.MethodPost {
+			http.Error(w, `{"error":{"code":400,"message":"invalid free/busy request"}}`, http.StatusBadRequest)
+			return
+		}
+		result := calendar.FreeBusyResponse{Calendars: map[string]calendar.FreeBusyCalendar{}}
+		for _, item := range req.Items {
+			if item.Id == "family" {
+				result.Calendars[item.Id] = calendar.FreeBusyCalendar{Busy: f.busy}
+			} else {
+				result.Calendars[item.Id] = calendar.FreeBusyCalendar{Errors: []*calendar.Error{{Domain: "global", Reason: "notFound"}}}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+	if !strings.HasSuffix(r.URL.Path, "/calendars/family/events") {
+		http.NotFound(w, r)
+		return
+	}
+
+	result := calendar.Events{}
+	if token := query.Get("syncToken"); token != "" {
+		if query.Get("timeMin") != "" || query.Get("orderBy") != "" {
+			http.Error(w, `{"error":{"code":400,"message":"invalid sync request"}}`, http.StatusBadRequest)
+			return
+		}
+		if f.expired[token] {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"error":{"code":410,"message":"Sync token is no longer valid, a full sync is required."}}`))
+			return
+		}
+		f.deltaSyncs++
+		result.Items = f.deltas[token]
+		result.NextSyncToken = f.nextToken
+	} else {
+		page := 0
+		if pageToken := query.Get("pageToken"); pageToken == "" {
+			f.fullSyncs++
+		} else {
+			page, _ = strconv.Atoi(strings.TrimPrefix(pageToken, "page-"))
+		}
+		result.Items = f.fullSync[page]
+		if page+1 < len(f.fullSync) {
+			result.NextPageToken = fmt.Sprintf("page-%d", page+1)
+		} else {
+			result.NextSyncToken = f.nextToken
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func newTestGoogleSource(t *testing.T, ts *httptest.Server, snapshots *snapshot.Store) *googleSource {
+	svc, err := calendar.NewService(context.Background(),
+		option.WithEndpoint(ts.URL+"/"),
+		option.WithHTTPClient(ts.Client()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create calendar service: %v", err)
+	}
+	return &googleSource{
+		calendarSvc: svc,
+		calendarID:  "family",
+		retryConfig: retry.DefaultConfig(),
+		snapshots:   snapshots,
+		now:         time.Now,
+	}
+}
+
+func newTimedEvent(id, summary, start, end string) *calendar.Event {
+	return &calendar.Event{
+		Id:      id,
+		Summary: summary,
+		Start:   &calendar.EventDateTime{DateTime: start},
+		End:     &calendar.EventDateTime{DateTime: end},
+	}
+}
+
+func eventSummaries(events []models.CalendarEvent) string {
+	var summaries []string
+	for _, ev := range events {
+		summaries = append(summaries, ev.Summary)
+	}
+	return strings.Join(summaries, ",")
+}
+
+func TestGoogleSourceIncrementalSync(t *testing.T) {
+	fake := &fakeGoogleCalendar{
+		fullSync: [][]*calendar.Event{
+			{newTimedEvent("piano", "Piano", "2024-01-11T16:00:00Z", "2024-01-11T17:00:00Z")},
+			{
+				newTimedEvent("dentist", "Dentist", "2024-01-09T09:00:00Z", "2024-01-09T10:00:00Z"),
+				{Id: "vacation", Summary: "Vacation", Start: &calendar.EventDateTime{Date: "2024-01-12"}, End: &calendar.EventDateTime{Date: "2024-01-14"}},
+			},
+		},
+		deltas: map[string][]*calendar.Event{
+			"token-1": {
+				{Id: "dentist", Status: "cancelled"},
+				newTimedEvent("piano", "Piano (moved)", "2024-01-10T16:00:00Z", "2024-01-10T17:00:00Z"),
+				newTimedEvent("game", "Game", "2024-01-13T18:00:00Z", "2024-01-13T20:00:00Z"),
+			},
+		},
+		expired:   map[string]bool{"token-2": true},
+		nextToken: "token-1",
+	}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	snapshots, err := snapshot.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create snapshot store: %v", err)
+	}
+
+	timeMin := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	timeMax := time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)
+
+	// The first fetch is a full sync across both pages
+	src := newTestGoogleSource(t, ts, snapshots)
+	events, err := src.Events(context.Background(), timeMin, timeMax)
+	if err != nil {
+		t.Fatalf("Failed to fetch events: %v", err)
+	}
+	if want := "Dentist,Piano,Vacation"; eventSummaries(events) != want {
+		t.Errorf("Expected %s after the full sync, got %s", want, eventSummaries(events))
+	}
+
+	// The second fetch only applies the changes, including the cancellation
+	fake.nextToken = "token-2"
+	events, err = src.Events(context.Background(), timeMin, timeMax)
+	if err != nil {
+		t.Fatalf("Failed to fetch events: %v", err)
+	}
+	if want := "Piano (moved),Vacation,Game"; eventSummaries(events) != want {
+		t.Errorf("Expected %s after the incremental sync, got %s", want, eventSummaries(events))
+	}
+	if fake.fullSyncs != 1 || fake.deltaSyncs != 1 {
+		t.Errorf("Expected 1 full and 1 incremental sync, got %d and %d", fake.fullSyncs, fake.deltaSyncs)
+	}
+
+	// After a restart the persisted store is reused; its token has expired, so
+	// the source falls back to a full sync
+	fake.nextToken = "token-3"
+	restarted := newTestGoogleSource(t, ts, snapshots)
+	events, err = restarted.Events(context.Background(), timeMin, timeMax)
+	if err != nil {
+		t.Fatalf("Failed to fetch events after restart: %v", err)
+	}
+	if want := "Dentist,Piano,Vacation"; eventSummaries(events) != want {
+		t.Errorf("Expected %s after the resync, got %s", want, eventSummaries(events))
+	}
+	if fake.fullSyncs != 2 {
+		t.Errorf("Expected a full resync after 410 Gone, got %d full syncs", fake.fullSyncs)
+	}
+	if restarted.state.SyncToken != "token-3" {
+		t.Errorf("Expected the new sync token to be stored, got %q", restarted.state.SyncToken)
+	}
+}
+
+func TestGoogleSourceReusesPersistedState(t *testing.T) {
+	fake := &fakeGoogleCalendar{
+		fullSync: [][]*calendar.Event{
+			{newTimedEvent("piano", "Piano", "2024-01-11T16:00:00Z", "2024-01-11T17:00:00Z")},
+		},
+		deltas:    map[string][]*calendar.Event{},
+		nextToken: "token-1",
+	}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	snapshots, err := snapshot.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create snapshot store: %v", err)
+	}
+
+	timeMin := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	timeMax := time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)
+
+	if _, err := newTestGoogleSource(t, ts, snapshots).Events(context.Background(), timeMin, timeMax); err != nil {
+		t.Fatalf("Failed to fetch events: %v", err)
+	}
+
+	// A restarted source continues from the persisted sync token
+	events, err := newTestGoogleSource(t, ts, snapshots).Events(context.Background(), timeMin.AddDate(0, 0, 1), timeMax.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("Failed to fetch events after restart: %v", err)
+	}
+	if eventSummaries(events) != "Piano" {
+		t.Errorf("Expected the persisted events, got %s", eventSummaries(events))
+	}
+	if fake.fullSyncs != 1 || fake.deltaSyncs != 1 {
+		t.Errorf("Expected 1 full and 1 incremental sync, got %d and %d", fake.fullSyncs, fake.deltaSyncs)
+	}
+
+	// A window reaching past the synced range needs a full sync
+	if _, err := newTestGoogleSource(t, ts, snapshots).Events(context.Background(), timeMin, timeMax.AddDate(0, 2, 0)); err != nil {
+		t.Fatalf("Failed to fetch events: %v", err)
+	}
+	if fake.fullSyncs != 2 {
+		t.Errorf("Expected a full sync for a wider window, got %d full syncs", fake.fullSyncs)
+	}
+}
+
+func TestGoogleSourceSavesStateOnlyWhenChanged(t *testing.T) {
+	fake := &fakeGoogleCalendar{
+		fullSync: [][]*calendar.Event{
+			{newTimedEvent("piano", "Piano", "2024-01-11T16:00:00Z", "2024-01-11T17:00:00Z")},
+		},
+		deltas: map[string][]*calendar.Event{
+			"token-2": {newTimedEvent("game", "Game", "2024-01-13T18:00:00Z", "2024-01-13T20:00:00Z")},
+		},
+		nextToken: "token-1",
+	}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	snapshots, err := snapshot.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create snapshot store: %v", err)
+	}
+
+	timeMin := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	timeMax := time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2024, 1, 7, 8, 0, 0, 0, time.UTC)
+	src := newTestGoogleSource(t, ts, snapshots)
+	src.now = func() time.Time { return now }
+
+	savedAt := func() time.Time {
+		var state googleSyncState
+		saved, err := snapshots.Load(src.syncStateName(), &state)
+		if err != nil {
+			t.Fatalf("Failed to load sync state: %v", err)
+		}
+		return saved
+	}
+
+	// The full sync is saved with the injected time
+	if _, err := src.Events(context.Background(), timeMin, timeMax); err != nil {
+		t.Fatalf("Failed to fetch events: %v", err)
+	}
+	if saved := savedAt(); !saved.Equal(now) {
+		t.Errorf("Expected the state saved at %v, got %v", now, saved)
+	}
+
+	// A sync without changes leaves the saved state alone
+	fullSyncAt := now
+	now = now.Add(15 * time.Minute)
+	fake.nextToken = "token-2"
+	if _, err := src.Events(context.Background(), timeMin, timeMax); err != nil {
+		t.Fatalf("Failed to fetch events: %v", err)
+	}
+	if saved := savedAt(); !saved.Equal(fullSyncAt) {
+		t.Errorf("Expected the state not to be saved again, got it saved at %v", saved)
+	}
+
+	// A sync with changes saves it
+	now = now.Add(15 * time.Minute)
+	if _, err := src.Events(context.Background(), timeMin, timeMax); err != nil {
+		t.Fatalf("Failed to fetch events: %v", err)
+	}
+	if saved := savedAt(); !saved.Equal(now) {
+		t.Errorf("Expected the changed state saved at %v, got %v", now, saved)
+	}
+}
+
+func TestFetchCalendarEventsReadsAllPages(t *testing.T) {
+	var pages [][]*calendar.Event
+	for page := 0; page < 4; page++ {
+		var items []*calendar.Event
+		for i := 0; i < 3; i++ {
+			day := page*3 + i + 1
+			items = append(items, newTimedEvent(fmt.Sprintf("event-%d", day), fmt.Sprintf("Event %d", day),
+				fmt.Sprintf("2024-01-%02dT09:00:00Z", day), fmt.Sprintf("2024-01-%02dT10:00:00Z", day)))
+		}
+		pages = append(pages, items)
+	}
+	fake := &fakeGoogleCalendar{fullSync: pages, nextToken: "token-1"}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	src := newTestGoogleSource(t, ts, nil)
+	result, err := fetchCalendarEventsWithRetry(context.Background(), src.calendarSvc, src.retryConfig, "family", eventsListParams{
+		timeMin: "2024-01-01T00:00:00Z",
+		timeMax: "2024-02-01T00:00:00Z",
+	})
+	if err != nil {
+		t.Fatalf("Failed to fetch events: %v", err)
+	}
+
+	if len(result.Items) != 12 {
+		t.Errorf("Expected the 12 events of all 4 pages, got %d", len(result.Items))
+	}
+	if result.NextSyncToken != "token-1" {
+		t.Errorf("Expected the sync token of the last page, got %q", result.NextSyncToken)
+	}
+	if fake.fullSyncs != 1 {
+		t.Errorf("Expected a single listing, got %d", fake.fullSyncs)
+	}
+}
+
+func TestGoogleEventOmitsGoogleFields(t *testing.T) {
+	item := &calendar.Event{
+		Id:          "abc123_20240110T170000Z",
+		Etag:        `"3384"`,
+		Kind:        "calendar#event",
+		HtmlLink:    "https://www.google.com/calendar/event?eid=abc",
+		ICalUID:     "abc123@google.com",
+		Summary:     "Piano",
+		Description: "Bring the books",
+		Location:    "Music school",
+		ColorId:     "5",
+		Creator:     &calendar.EventCreator{Email: "parent@example.com"},
+		Start:       &calendar.EventDateTime{DateTime: "2024-01-10T17:00:00-05:00", TimeZone: "America/New_York"},
+		End:         &calendar.EventDateTime{DateTime: "2024-01-10T18:00:00-05:00", TimeZone: "America/New_York"},
+	}
+
+	data, err := json.Marshal(googleEvent(item, map[string]string{"5": "yellow"}))
+	if err != nil {
+		t.Fatalf("Failed to encode event: %v", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	allowed := map[string]bool{"id": true, "summary": true, "description": true, "location": true, "start": true, "end": true, "eventColor": true}
+	for key := range fields {
+		if !allowed[key] {
+			t.Errorf("Unexpected field %q in the event contract", key)
+		}
+	}
+
+	event := googleEvent(item, nil)
+	if event.Start.DateTime != item.Start.DateTime || event.Start.TimeZone != "America/New_York" {
+		t.Errorf("Expected start %s in America/New_York, got %+v", item.Start.DateTime, event.Start)
+	}
+}
+
+func TestGoogleColorsDoesNotBlockOtherCalendars(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"event": {"11": {"background": "#dc2127"}}}`))
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	src := newTestGoogleSource(t, ts, nil)
+	colors := &googleColors{calendarSvc: src.calendarSvc, retryConfig: src.retryConfig}
+
+	go colors.eventColorNames(context.Background())
+	time.Sleep(20 * time.Millisecond)
+
+	// A calendar giving up doesn't have to wait for the fetch in flight
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan map[string]string)
+	go func() { done <- colors.eventColorNames(ctx) }()
+
+	select {
+	case names := <-done:
+		if names != nil {
+			t.Errorf("Expected no colors for a cancelled fetch, got %v", names)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the second caller not to wait for the first fetch")
+	}
+}
+
+func TestGoogleSourceResolvesEventColors(t *testing.T) {
+	tomato := newTimedEvent("dentist", "Dentist", "2024-01-09T09:00:00Z", "2024-01-09T10:00:00Z")
+	tomato.ColorId = "11"
+	basil := newTimedEvent("game", "Game", "2024-01-10T18:00:00Z", "2024-01-10T20:00:00Z")
+	basil.ColorId = "10"
+	fake := &fakeGoogleCalendar{
+		fullSync: [][]*calendar.Event{{
+			tomato,
+			basil,
+			newTimedEvent("piano", "Piano", "2024-01-11T16:00:00Z", "2024-01-11T17:00:00Z"),
+		}},
+		deltas:      map[string][]*calendar.Event{},
+		eventColors: map[string]string{"9": "#5484ed", "10": "#51b749", "11": "#dc2127"},
+		nextToken:   "token-1",
+	}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	src := newTestGoogleSource(t, ts, nil)
+	src.colors = &googleColors{calendarSvc: src.calendarSvc, retryConfig: src.retryConfig}
+
+	timeMin := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	timeMax := time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		events, err := src.Events(context.Background(), timeMin, timeMax)
+		if err != nil {
+			t.Fatalf("Failed to fetch events: %v", err)
+		}
+
+		var colors []string
+		for _, event := range events {
+			colors = append(colors, event.EventColor)
+		}
+		if got := strings.Join(colors, ","); got != "red,green," {
+			t.Errorf("Expected event colors red,green and none, got %s", got)
+		}
+	}
+
+	if fake.colorGets != 1 {
+		t.Errorf("Expected the color definitions to be fetched once, got %d", fake.colorGets)
+	}
+}
+
+func TestNearestPaletteColor(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want string
+		ok   bool
+	}{
+		{"#dc2127", "red", true},
+		{"#51b749", "green", true},
+		{"#5484ed", "blue", true},
+		{"not a color", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := nearestPaletteColor(tt.hex)
+		if ok != tt.ok || (tt.want != "" && got != tt.want) {
+			t.Errorf("nearestPaletteColor(%q) = %q, %v, want %q, %v", tt.hex, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package calendarservice
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+// HandleGetCalendarGrid serves the calendar as the weeks×7 day grid the
+// display renders, with every event in each day it covers
+func (s *Service) HandleGetCalendarGrid(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	snap, err := s.snapshotFor(r).forPerson(r.URL.Query().Get("person"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, snap.grid(s.now()))
+}
+
+// grid buckets the snapshot's events into the days of the window
+func (c *calendarSnapshot) grid(now time.Time) models.CalendarData {
+	loc := c.start.Location()
+	today := now.In(loc)
+
+	numDays := c.numberOfDays()
+	gridStart, gridEnd := c.start, c.end
+
+	days := make([]models.DayData, 0, numDays)
+	for i := 0; i < numDays; i++ {
+		day := time.Date(gridStart.Year(), gridStart.Month(), gridStart.Day()+i, 0, 0, 0, 0, loc)
+		next := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+
+		events := []models.CalendarEvent{}
+		for _, event := range c.events {
+			if eventOverlaps(event, day, next) {
+				events = append(events, event)
+			}
+		}
+		sortDayEvents(events, loc)
+
+		days = append(days, models.DayData{
+			Date:           day.Format(scheduleDateLayout),
+			Events:         events,
+			IsToday:        sameDay(day, today),
+			IsCurrentMonth: day.Year() == today.Year() && day.Month() == today.Month(),
+			Lanes:          []models.EventLane{},
+		})
+	}
+	layoutLanes(days, gridStart, gridEnd, loc)
+
+	return models.CalendarData{
+		Version:        models.CalendarAPIVersion,
+		StartDate:      c.start.Format(time.RFC3339),
+		EndDate:        c.end.Format(time.RFC3339),
+		StartOnSunday:  c.config.StartOnSunday,
+		NumberOfWeeks:  c.numberOfWeeks(),
+		Timezone:       loc.String(),
+		Events:         days,
+		CalendarStatus: c.statuses,
+		Stale:          c.stale(),
+		Truncated:      c.truncated(),
+		FetchedAt:      c.fetchedAt.Format(time.RFC3339),
+	}
+}
+
+// eventSpan is the run of grid days a multi-day event covers
+type eventSpan struct {
+	id          string
+	first, last int  // Indexes of the first and last day in the grid
+	before      bool // The event starts before the grid
+	after       bool // The event ends after the grid
+}
+
+// layoutLanes assigns every event covering more than one day a lane, the
+// lowest one that is free on all of its days, and records it on each day.
+// Longer events starting on the same day get the lower lanes.
+func layoutLanes(days []models.DayData, gridStart, gridEnd time.Time, loc *time.Location) {
+	spans := map[string]*eventSpan{}
+	var order []*eventSpan
+	for i, day := range days {
+		for _, event := range day.Events {
+			span, ok := spans[event.ID]
+			if !ok {
+				start, end, _ := eventRange(event, loc)
+				span = &eventSpan{id: event.ID, first: i, before: start.Before(gridStart), after: end.After(gridEnd)}
+				spans[event.ID] = span
+				order = append(order, span)
+			}
+			span.last = i
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].first != order[j].first {
+			return order[i].first < order[j].first
+		}
+		return order[i].last-order[i].first > order[j].last-order[j].first
+	})
+
+	var laneEnds []int // Last day occupied in each lane
+	for _, span := range order {
+		if span.first == span.last && !span.before && !span.after {
+			continue
+		}
+
+		lane := 0
+		for lane < len(laneEnds) && laneEnds[lane] >= span.first {
+			lane++
+		}
+		if lane == len(laneEnds) {
+			laneEnds = append(laneEnds, 0)
+		}
+		laneEnds[lane] = span.last
+
+		for i := span.first; i <= span.last; i++ {
+			days[i].Lanes = append(days[i].Lanes, models.EventLane{
+				EventID:       span.id,
+				Lane:          lane,
+				ContinuesFrom: i > span.first || span.before,
+				ContinuesInto: i < span.last || span.after,
+			})
+		}
+	}
+
+	for _, day := range days {
+		sort.Slice(day.Lanes, func(i, j int) bool { return day.Lanes[i].Lane < day.Lanes[j].Lane })
+	}
+}
+
+// sortDayEvents orders the events of a day: all-day events first, then by
+// start time
+func sortDayEvents(events []models.CalendarEvent, loc *time.Location) {
+	sort.SliceStable(events, func(i, j int) bool {
+		allDayI, allDayJ := events[i].Start.DateTime == "", events[j].Start.DateTime == ""
+		if allDayI != allDayJ {
+			return allDayI
+		}
+		a, _, _ := eventRange(events[i], loc)
+		b, _, _ := eventRange(events[j], loc)
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return events[i].ID < events[j].ID
+	})
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
@@ -0,0 +1,190 @@
+package calendarservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+func TestCalendarGrid(t *testing.T) {
+	snap := &calendarSnapshot{
+		config: models.CalendarConfig{NumberOfWeeks: 2, StartOnSunday: true},
+		start:  time.Date(2024, 1, 28, 0, 0, 0, 0, time.UTC),
+		end:    time.Date(2024, 2, 11, 0, 0, 0, 0, time.UTC),
+		events: []models.CalendarEvent{
+			{ID: "dentist", Summary: "Dentist", Start: models.EventTime{DateTime: "2024-01-31T09:00:00Z"}, End: models.EventTime{DateTime: "2024-01-31T10:00:00Z"}},
+			{ID: "sleepover", Summary: "Sleepover", Start: models.EventTime{DateTime: "2024-02-02T20:00:00Z"}, End: models.EventTime{DateTime: "2024-02-03T10:00:00Z"}},
+			{ID: "break", Summary: "Break", Start: models.EventTime{Date: "2024-01-29"}, End: models.EventTime{Date: "2024-02-01"}},
+			{ID: "breakfast", Summary: "Breakfast", Start: models.EventTime{DateTime: "2024-01-31T07:00:00Z"}, End: models.EventTime{DateTime: "2024-01-31T08:00:00Z"}},
+		},
+	}
+
+	grid := snap.grid(time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC))
+
+	if len(grid.Events) != 14 {
+		t.Fatalf("Expected 14 days, got %d", len(grid.Events))
+	}
+	if grid.Events[0].Date != "2024-01-28" || grid.Events[13].Date != "2024-02-10" {
+		t.Errorf("Expected days 2024-01-28..2024-02-10, got %s..%s", grid.Events[0].Date, grid.Events[13].Date)
+	}
+
+	expected := map[string]string{
+		"2024-01-28": "",
+		"2024-01-29": "Break",
+		"2024-01-30": "Break",
+		"2024-01-31": "Break,Breakfast,Dentist",
+		"2024-02-01": "",
+		"2024-02-02": "Sleepover",
+		"2024-02-03": "Sleepover",
+	}
+	for _, day := range grid.Events {
+		var summaries []string
+		for _, ev := range day.Events {
+			summaries = append(summaries, ev.Summary)
+		}
+		if want, ok := expected[day.Date]; ok && strings.Join(summaries, ",") != want {
+			t.Errorf("Expected %s on %s, got %s", want, day.Date, strings.Join(summaries, ","))
+		}
+
+		if want := day.Date == "2024-01-31"; day.IsToday != want {
+			t.Errorf("Expected isToday %v on %s", want, day.Date)
+		}
+		if want := strings.HasPrefix(day.Date, "2024-01"); day.IsCurrentMonth != want {
+			t.Errorf("Expected isCurrentMonth %v on %s", want, day.Date)
+		}
+	}
+}
+
+func TestHandleGetCalendarGrid(t *testing.T) {
+	svc := &Service{
+		calendarConfig: models.CalendarConfig{NumberOfWeeks: 3},
+		sources: []calendarSource{{
+			config: models.CalendarEntry{CalendarID: "family", Color: "blue"},
+			source: &fakeSource{events: []models.CalendarEvent{{ID: "google-1", Summary: "Dentist", Start: models.EventTime{Date: "2024-01-10"}, End: models.EventTime{Date: "2024-01-11"}}}},
+		}},
+		now: func() time.Time {
+			return time.Date(2024, 1, 10, 12, 0, 0, 0, time.Local)
+		},
+	}
+
+	w := httptest.NewRecorder()
+	svc.HandleGetCalendarGrid(w, httptest.NewRequest(http.MethodGet, "/api/calendar/grid", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response models.CalendarData
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Events) != 21 {
+		t.Fatalf("Expected 21 days, got %d", len(response.Events))
+	}
+
+	// The week starts on Monday, so Wednesday is the third day
+	today := response.Events[2]
+	if today.Date != "2024-01-10" || !today.IsToday {
+		t.Errorf("Expected 2024-01-10 to be today, got %+v", today)
+	}
+	if len(today.Events) != 1 || today.Events[0].ColorID != "blue" {
+		t.Errorf("Expected the dentist on today, got %+v", today.Events)
+	}
+}
+
+func TestCalendarGridLanesKeepBoth(t *testing.T) {
+	trip := func(calendar string) models.CalendarEvent {
+		return models.CalendarEvent{
+			ID:        "trip",
+			ICalUID:   "trip@example.com",
+			Summary:   "School trip",
+			Start:     models.EventTime{Date: "2024-02-05"},
+			End:       models.EventTime{Date: "2024-02-08"},
+			Calendars: []models.EventCalendar{{Name: calendar}},
+		}
+	}
+	snap := &calendarSnapshot{
+		config: models.CalendarConfig{NumberOfWeeks: 2, StartOnSunday: true},
+		start:  time.Date(2024, 1, 28, 0, 0, 0, 0, time.UTC),
+		end:    time.Date(2024, 2, 11, 0, 0, 0, 0, time.UTC),
+		events: mergeDuplicates([]models.CalendarEvent{trip("family"), trip("parent")}, "keep_both", time.UTC),
+	}
+
+	grid := snap.grid(time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC))
+
+	for _, day := range grid.Events {
+		if day.Date < "2024-02-05" || day.Date > "2024-02-07" {
+			continue
+		}
+		var lanes []string
+		for _, lane := range day.Lanes {
+			lanes = append(lanes, fmt.Sprintf("%s:%d", lane.EventID, lane.Lane))
+		}
+		if len(day.Events) != 2 || strings.Join(lanes, " ") != "trip:0 trip@parent:1" {
+			t.Errorf("Expected both copies in their own lane on %s, got %d events and lanes %v", day.Date, len(day.Events), lanes)
+		}
+	}
+}
+
+func TestCalendarGridLanes(t *testing.T) {
+	allDay := func(id, start, end string) models.CalendarEvent {
+		return models.CalendarEvent{ID: id, Summary: id, Start: models.EventTime{Date: start}, End: models.EventTime{Date: end}}
+	}
+	snap := &calendarSnapshot{
+		config: models.CalendarConfig{NumberOfWeeks: 2, StartOnSunday: true},
+		start:  time.Date(2024, 1, 28, 0, 0, 0, 0, time.UTC),
+		end:    time.Date(2024, 2, 11, 0, 0, 0, 0, time.UTC),
+		events: []models.CalendarEvent{
+			allDay("break", "2024-01-25", "2024-01-30"),
+			allDay("dentist", "2024-02-01", "2024-02-02"),
+			allDay("conference", "2024-02-02", "2024-02-04"),
+			allDay("vacation", "2024-02-01", "2024-02-06"),
+			allDay("camp", "2024-02-05", "2024-02-09"),
+			allDay("summer", "2024-02-09", "2024-02-20"),
+		},
+	}
+
+	grid := snap.grid(time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC))
+
+	// Each lane is formatted as id:lane with < when the event continues from
+	// the previous day and > when it continues into the next
+	expected := map[string]string{
+		"2024-01-28": "<break:0>",
+		"2024-01-29": "<break:0",
+		"2024-01-30": "",
+		"2024-02-01": "vacation:0>",
+		"2024-02-02": "<vacation:0> conference:1>",
+		"2024-02-03": "<vacation:0> <conference:1",
+		"2024-02-04": "<vacation:0>",
+		"2024-02-05": "<vacation:0 camp:1>",
+		"2024-02-08": "<camp:1",
+		"2024-02-09": "summer:0>",
+		"2024-02-10": "<summer:0>",
+	}
+	for _, day := range grid.Events {
+		want, ok := expected[day.Date]
+		if !ok {
+			continue
+		}
+		var lanes []string
+		for _, lane := range day.Lanes {
+			s := fmt.Sprintf("%s:%d", lane.EventID, lane.Lane)
+			if lane.ContinuesFrom {
+				s = "<" + s
+			}
+			if lane.ContinuesInto {
+				s += ">"
+			}
+			lanes = append(lanes, s)
+		}
+		if got := strings.Join(lanes, " "); got != want {
+			t.Errorf("Expected lanes %q on %s, got %q", want, day.Date, got)
+		}
+	}
+}
@@ -0,0 +1,913 @@
+package calendarservice
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/recurrence"
+	"github.com/coreyk/piinky/backend-go/retry"
+)
+
+// Layouts of iCalendar DATE and DATE-TIME values
+const (
+	icsDateLayout     = "20060102"
+	icsDateTimeLayout = "20060102T150405"
+)
+
+// icsSource reads events from an iCalendar feed, either over HTTP(S) or from
+// a local file
+type icsSource struct {
+	url         string
+	path        string
+	httpClient  HTTPClient
+	retryConfig retry.Config
+}
+
+func (s *icsSource) Events(ctx context.Context, timeMin, timeMax time.Time) ([]models.CalendarEvent, error) {
+	data, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cal, err := parseICS(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return calendarEventsFromICS([]*icsComponent{cal}, timeMin, timeMax), nil
+}
+
+// calendarEventsFromICS expands the VEVENTs of the given calendars into the
+// non-cancelled events overlapping [timeMin, timeMax). Floating times and
+// all-day dates are in the timezone of timeMin.
+func calendarEventsFromICS(cals []*icsComponent, timeMin, timeMax time.Time) []models.CalendarEvent {
+	var icsEvents []*icsEvent
+	for _, cal := range cals {
+		icsEvents = append(icsEvents, parseICSEvents(cal, timeMin.Location())...)
+	}
+
+	var events []models.CalendarEvent
+	for _, ev := range expandICSEvents(icsEvents, timeMin, timeMax) {
+		if ev.status == "CANCELLED" {
+			continue
+		}
+		events = append(events, ev.toCalendarEvent())
+	}
+
+	return events
+}
+
+// load returns the raw feed contents
+func (s *icsSource) load(ctx context.Context) ([]byte, error) {
+	if s.url == "" {
+		data, err := os.ReadFile(s.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ics file: %v", err)
+		}
+		return data, nil
+	}
+
+	url := s.url
+	if strings.HasPrefix(url, "webcal://") {
+		url = "https://" + strings.TrimPrefix(url, "webcal://")
+	}
+
+	return retry.DoWithResult(ctx, s.retryConfig, func() ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch ics feed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			if retry.IsTransientStatusCode(resp.StatusCode) {
+				return nil, fmt.Errorf("ics feed returned transient error: status code %d", resp.StatusCode)
+			}
+			return nil, fmt.Errorf("ics feed error: status code %d", resp.StatusCode)
+		}
+
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ics feed: %v", err)
+		}
+		return data, nil
+	})
+}
+
+// icsProperty is a single content line of an iCalendar object
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// icsComponent is a BEGIN/END block with its properties and nested components
+type icsComponent struct {
+	Name       string
+	Properties []icsProperty
+	Components []*icsComponent
+}
+
+// prop returns the first property with the given name
+func (c *icsComponent) prop(name string) (icsProperty, bool) {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return icsProperty{}, false
+}
+
+// props returns all properties with the given name
+func (c *icsComponent) props(name string) []icsProperty {
+	var props []icsProperty
+	for _, p := range c.Properties {
+		if p.Name == name {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+// text returns the unescaped TEXT value of the first property with the given name
+func (c *icsComponent) text(name string) string {
+	p, ok := c.prop(name)
+	if !ok {
+		return ""
+	}
+	return unescapeICSText(p.Value)
+}
+
+// children returns the nested components with the given name
+func (c *icsComponent) children(name string) []*icsComponent {
+	var comps []*icsComponent
+	for _, child := range c.Components {
+		if child.Name == name {
+			comps = append(comps, child)
+		}
+	}
+	return comps
+}
+
+// parseICS parses an iCalendar stream and returns its VCALENDAR component.
+// Malformed content lines, like a vendor property with a broken parameter, are
+// logged and skipped; only a broken BEGIN/END structure fails the feed.
+func parseICS(r io.Reader) (*icsComponent, error) {
+	lines, err := unfoldICSLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &icsComponent{}
+	stack := []*icsComponent{root}
+
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		prop, err := parseICSLine(line)
+		if err != nil {
+			if isICSDelimiter(line) {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			log.Printf("Skipping ics line %d: %v", i+1, err)
+			continue
+		}
+
+		current := stack[len(stack)-1]
+		switch prop.Name {
+		case "BEGIN":
+			comp := &icsComponent{Name: strings.ToUpper(prop.Value)}
+			current.Components = append(current.Components, comp)
+			stack = append(stack, comp)
+		case "END":
+			if len(stack) == 1 || current.Name != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", i+1, prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			current.Properties = append(current.Properties, prop)
+		}
+	}
+
+	if len(stack) != 1 {
+		return nil, fmt.Errorf("unterminated %s component", stack[len(stack)-1].Name)
+	}
+
+	cals := root.children("VCALENDAR")
+	if len(cals) == 0 {
+		return nil, fmt.Errorf("no VCALENDAR component found")
+	}
+	return cals[0], nil
+}
+
+// isICSDelimiter reports whether a content line begins or ends a component
+func isICSDelimiter(line string) bool {
+	name := strings.ToUpper(line)
+	if end := strings.IndexAny(name, ";:"); end >= 0 {
+		name = name[:end]
+	}
+	name = strings.TrimSpace(name)
+	return name == "BEGIN" || name == "END"
+}
+
+// unfoldICSLines splits the stream into logical lines, joining folded
+// continuation lines
+func unfoldICSLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ics data: %v", err)
+	}
+	return lines, nil
+}
+
+// parseICSLine parses a content line of the form NAME;PARAM=VALUE:VALUE
+func parseICSLine(line string) (icsProperty, error) {
+	prop := icsProperty{Params: map[string]string{}}
+
+	nameEnd := strings.IndexAny(line, ";:")
+	if nameEnd <= 0 {
+		return prop, fmt.Errorf("malformed content line %q", line)
+	}
+	prop.Name = strings.ToUpper(line[:nameEnd])
+
+	rest := line[nameEnd:]
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			return prop, fmt.Errorf("malformed parameter in %q", line)
+		}
+		key := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+
+		// Parameter values may be quoted to contain ';', ':' or ','
+		var value strings.Builder
+		inQuotes := false
+		i := 0
+		for ; i < len(rest); i++ {
+			ch := rest[i]
+			if ch == '"' {
+				inQuotes = !inQuotes
+				continue
+			}
+			if !inQuotes && (ch == ';' || ch == ':') {
+				break
+			}
+			value.WriteByte(ch)
+		}
+		prop.Params[key] = value.String()
+		rest = rest[i:]
+	}
+
+	if !strings.HasPrefix(rest, ":") {
+		return prop, fmt.Errorf("missing value in %q", line)
+	}
+	prop.Value = rest[1:]
+
+	return prop, nil
+}
+
+// unescapeICSText reverses the escaping of TEXT values
+func unescapeICSText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// icsTime is a resolved DATE or DATE-TIME value. zone is set when the TZID
+// was resolved from a VTIMEZONE definition instead of the tz database.
+type icsTime struct {
+	Time   time.Time
+	AllDay bool
+	TZID   string
+	zone   *icsTimezone
+}
+
+// clock returns the time on the clock recurrences are computed on: the value's
+// own location, or a UTC-labelled wall clock for VTIMEZONE zones
+func (t icsTime) clock() time.Time {
+	if t.zone != nil {
+		return t.zone.wallClock(t.Time)
+	}
+	return t.Time
+}
+
+// fromClock converts a time produced on clock() back into an instant
+func (t icsTime) fromClock(c time.Time) time.Time {
+	if t.zone != nil {
+		return t.zone.fromWallClock(c)
+	}
+	return c
+}
+
+// icsEvent is a VEVENT with its dates resolved
+type icsEvent struct {
+	uid         string
+	summary     string
+	description string
+	location    string
+	status      string
+	transp      string
+	class       string
+	organizer   string
+	attendees   []string
+	start       icsTime
+	end         icsTime
+
+	// Recurrence properties of a master event
+	rrules  []string
+	rdates  []icsTime
+	exdates []icsTime
+
+	// recurrenceID identifies the instance of a recurring event this is
+	recurrenceID *icsTime
+}
+
+// overlaps reports whether the event intersects [timeMin, timeMax)
+func (e *icsEvent) overlaps(timeMin, timeMax time.Time) bool {
+	end := e.end.Time
+	// Zero-length events still occupy their start instant
+	if !end.After(e.start.Time) {
+		end = e.start.Time.Add(time.Nanosecond)
+	}
+	return e.start.Time.Before(timeMax) && end.After(timeMin)
+}
+
+// toCalendarEvent converts the event into the source-independent model
+func (e *icsEvent) toCalendarEvent() models.CalendarEvent {
+	event := models.CalendarEvent{
+		ID:           e.uid,
+		Summary:      e.summary,
+		Description:  e.description,
+		Location:     e.location,
+		ICalUID:      e.uid,
+		Start:        e.start.eventTime(),
+		End:          e.end.eventTime(),
+		Transparency: "opaque",
+		Visibility:   "default",
+		EventType:    "default",
+		Organizer:    e.organizer,
+		Attendees:    e.attendees,
+	}
+	if e.transp == "TRANSPARENT" {
+		event.Transparency = "transparent"
+	}
+	if e.class != "" {
+		event.Visibility = strings.ToLower(e.class)
+	}
+	if e.recurrenceID != nil {
+		// Match the instance IDs Google generates for expanded recurring events
+		event.ID = e.uid + "_" + e.recurrenceID.instanceSuffix()
+	}
+	return event
+}
+
+// icsMailAddress returns the email address of an ORGANIZER or ATTENDEE
+// "mailto:" URI
+func icsMailAddress(uri string) string {
+	if len(uri) >= 7 && strings.EqualFold(uri[:7], "mailto:") {
+		return uri[7:]
+	}
+	return uri
+}
+
+// instanceSuffix formats the original start of an instance for its ID
+func (t icsTime) instanceSuffix() string {
+	if t.AllDay {
+		return t.Time.Format(icsDateLayout)
+	}
+	return t.Time.UTC().Format(icsDateTimeLayout) + "Z"
+}
+
+func (t icsTime) eventTime() models.EventTime {
+	if t.AllDay {
+		return models.EventTime{Date: t.Time.Format("2006-01-02")}
+	}
+	return models.EventTime{
+		DateTime: t.Time.Format(time.RFC3339),
+		TimeZone: t.TZID,
+	}
+}
+
+// parseICSEvents resolves every VEVENT of the calendar. Floating times and
+// all-day dates are interpreted in loc. Malformed events are logged and skipped.
+func parseICSEvents(cal *icsComponent, loc *time.Location) []*icsEvent {
+	zones := parseICSTimezones(cal)
+
+	var events []*icsEvent
+	for _, comp := range cal.children("VEVENT") {
+		ev, err := parseICSEvent(comp, zones, loc)
+		if err != nil {
+			log.Printf("Skipping ics event %q: %v", comp.text("UID"), err)
+			continue
+		}
+		events = append(events, ev)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].start.Time.Before(events[j].start.Time)
+	})
+
+	return events
+}
+
+func parseICSEvent(comp *icsComponent, zones icsTimezones, loc *time.Location) (*icsEvent, error) {
+	ev := &icsEvent{
+		uid:         comp.text("UID"),
+		summary:     comp.text("SUMMARY"),
+		description: comp.text("DESCRIPTION"),
+		location:    comp.text("LOCATION"),
+		status:      strings.ToUpper(comp.text("STATUS")),
+		transp:      strings.ToUpper(comp.text("TRANSP")),
+		class:       strings.ToUpper(comp.text("CLASS")),
+	}
+	if organizer, ok := comp.prop("ORGANIZER"); ok {
+		ev.organizer = icsMailAddress(organizer.Value)
+	}
+	for _, attendee := range comp.props("ATTENDEE") {
+		ev.attendees = append(ev.attendees, icsMailAddress(attendee.Value))
+	}
+
+	dtstart, ok := comp.prop("DTSTART")
+	if !ok {
+		return nil, fmt.Errorf("missing DTSTART")
+	}
+	start, err := zones.parseTime(dtstart, loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DTSTART: %v", err)
+	}
+	ev.start = start
+
+	if dtend, ok := comp.prop("DTEND"); ok {
+		end, err := zones.parseTime(dtend, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DTEND: %v", err)
+		}
+		ev.end = end
+	} else if duration, ok := comp.prop("DURATION"); ok {
+		days, d, err := parseICSDuration(duration.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DURATION: %v", err)
+		}
+		ev.end = icsTime{Time: start.Time.AddDate(0, 0, days).Add(d), AllDay: start.AllDay, TZID: start.TZID}
+	} else if start.AllDay {
+		// An all-day event without an end lasts one day
+		ev.end = icsTime{Time: start.Time.AddDate(0, 0, 1), AllDay: true}
+	} else {
+		ev.end = start
+	}
+
+	for _, p := range comp.props("RRULE") {
+		ev.rrules = append(ev.rrules, p.Value)
+	}
+	if ev.rdates, err = zones.parseTimeList(comp.props("RDATE"), loc); err != nil {
+		return nil, fmt.Errorf("invalid RDATE: %v", err)
+	}
+	if ev.exdates, err = zones.parseTimeList(comp.props("EXDATE"), loc); err != nil {
+		return nil, fmt.Errorf("invalid EXDATE: %v", err)
+	}
+	if rid, ok := comp.prop("RECURRENCE-ID"); ok {
+		recurrenceID, err := zones.parseTime(rid, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RECURRENCE-ID: %v", err)
+		}
+		ev.recurrenceID = &recurrenceID
+	}
+
+	if ev.uid == "" {
+		ev.uid = fmt.Sprintf("%s-%d", strings.ToLower(strings.ReplaceAll(ev.summary, " ", "-")), start.Time.Unix())
+	}
+
+	return ev, nil
+}
+
+// expandICSEvents replaces recurring events with their instances overlapping
+// [timeMin, timeMax). Instances with a RECURRENCE-ID override are replaced by
+// the override, which is kept only if it overlaps the window itself.
+func expandICSEvents(events []*icsEvent, timeMin, timeMax time.Time) []*icsEvent {
+	overridden := map[string]map[int64]bool{}
+	for _, ev := range events {
+		if ev.recurrenceID == nil {
+			continue
+		}
+		if overridden[ev.uid] == nil {
+			overridden[ev.uid] = map[int64]bool{}
+		}
+		overridden[ev.uid][ev.recurrenceID.Time.UnixNano()] = true
+	}
+
+	var expanded []*icsEvent
+	for _, ev := range events {
+		if ev.recurrenceID != nil || (len(ev.rrules) == 0 && len(ev.rdates) == 0) {
+			if ev.overlaps(timeMin, timeMax) {
+				expanded = append(expanded, ev)
+			}
+			continue
+		}
+		expanded = append(expanded, ev.instances(timeMin, timeMax, overridden[ev.uid])...)
+	}
+
+	sort.SliceStable(expanded, func(i, j int) bool {
+		return expanded[i].start.Time.Before(expanded[j].start.Time)
+	})
+
+	return expanded
+}
+
+// instances expands a recurring event into the occurrences overlapping
+// [timeMin, timeMax), skipping the ones in overridden
+func (e *icsEvent) instances(timeMin, timeMax time.Time, overridden map[int64]bool) []*icsEvent {
+	set := &recurrence.Set{Start: e.start.clock()}
+	for _, r := range e.rrules {
+		rule, err := recurrence.ParseRule(r, set.Start.Location())
+		if err != nil {
+			log.Printf("Ignoring recurrence rule %q of ics event %q: %v", r, e.uid, err)
+			continue
+		}
+		set.Rules = append(set.Rules, rule)
+	}
+	for _, rdate := range e.rdates {
+		set.RDates = append(set.RDates, e.start.onClock(rdate))
+	}
+	for _, exdate := range e.exdates {
+		set.ExDates = append(set.ExDates, e.start.onClock(exdate))
+	}
+
+	// All-day events keep their length in days so DST can't shift them off midnight
+	days := 0
+	duration := e.end.Time.Sub(e.start.Time)
+	if e.start.AllDay {
+		days = int(math.Round(duration.Hours() / 24))
+		duration = 0
+	}
+
+	// Widen the window so instances starting before timeMin but still running,
+	// and wall clocks offset from UTC, are not missed
+	from := timeMin.AddDate(0, 0, -days).Add(-duration - 24*time.Hour)
+	to := timeMax.Add(24 * time.Hour)
+
+	var instances []*icsEvent
+	for _, occurrence := range set.Between(from, to) {
+		start := e.start
+		start.Time = e.start.fromClock(occurrence)
+		if overridden[start.Time.UnixNano()] {
+			continue
+		}
+
+		end := e.end
+		end.Time = start.Time.AddDate(0, 0, days).Add(duration)
+
+		instance := *e
+		instance.start = start
+		instance.end = end
+		instance.rrules, instance.rdates, instance.exdates = nil, nil, nil
+		instance.recurrenceID = &start
+
+		if instance.overlaps(timeMin, timeMax) {
+			instances = append(instances, &instance)
+		}
+	}
+
+	return instances
+}
+
+// onClock expresses another value of the same event on t's recurrence clock
+func (t icsTime) onClock(other icsTime) time.Time {
+	if t.zone != nil {
+		return t.zone.wallClock(other.Time)
+	}
+	if t.AllDay && other.AllDay {
+		return other.Time
+	}
+	return other.Time.In(t.Time.Location())
+}
+
+// parseICSDuration parses a DURATION value such as P1D, PT1H30M or -P1W into
+// whole days and the remaining time, so day arithmetic stays DST-correct
+func parseICSDuration(s string) (int, time.Duration, error) {
+	if s == "" {
+		return 0, 0, fmt.Errorf("empty duration")
+	}
+
+	sign := 1
+	switch s[0] {
+	case '-':
+		sign = -1
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") {
+		return 0, 0, fmt.Errorf("malformed duration %q", s)
+	}
+	s = s[1:]
+
+	var days int
+	var d time.Duration
+	inTime := false
+	num := ""
+	for _, ch := range s {
+		switch {
+		case ch >= '0' && ch <= '9':
+			num += string(ch)
+			continue
+		case ch == 'T':
+			inTime = true
+			continue
+		}
+
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, 0, fmt.Errorf("malformed duration %q", s)
+		}
+		num = ""
+
+		switch {
+		case ch == 'W' && !inTime:
+			days += 7 * n
+		case ch == 'D' && !inTime:
+			days += n
+		case ch == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case ch == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case ch == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		default:
+			return 0, 0, fmt.Errorf("malformed duration %q", s)
+		}
+	}
+	if num != "" {
+		return 0, 0, fmt.Errorf("malformed duration %q", s)
+	}
+
+	return sign * days, time.Duration(sign) * d, nil
+}
+
+// parseTimeList resolves RDATE and EXDATE properties, which may hold several
+// comma-separated values each. PERIOD values contribute their start.
+func (zones icsTimezones) parseTimeList(props []icsProperty, loc *time.Location) ([]icsTime, error) {
+	var times []icsTime
+	for _, p := range props {
+		for _, value := range strings.Split(p.Value, ",") {
+			value, _, _ = strings.Cut(value, "/")
+			t, err := zones.parseTime(icsProperty{Name: p.Name, Params: p.Params, Value: value}, loc)
+			if err != nil {
+				return nil, err
+			}
+			times = append(times, t)
+		}
+	}
+	return times, nil
+}
+
+// icsTimezones holds the VTIMEZONE definitions of a calendar by TZID
+type icsTimezones map[string]*icsTimezone
+
+// icsTimezone is a VTIMEZONE definition made up of STANDARD and DAYLIGHT
+// observances
+type icsTimezone struct {
+	tzid        string
+	observances []icsObservance
+}
+
+// icsObservance is a STANDARD or DAYLIGHT sub-component. Onsets are kept as
+// wall-clock times in UTC.
+type icsObservance struct {
+	start      time.Time
+	offsetFrom int
+	offsetTo   int
+	rrule      string
+	rdates     []time.Time
+}
+
+func parseICSTimezones(cal *icsComponent) icsTimezones {
+	zones := icsTimezones{}
+	for _, comp := range cal.children("VTIMEZONE") {
+		tz := &icsTimezone{tzid: comp.text("TZID")}
+		for _, sub := range comp.Components {
+			if sub.Name != "STANDARD" && sub.Name != "DAYLIGHT" {
+				continue
+			}
+			obs, err := parseICSObservance(sub)
+			if err != nil {
+				log.Printf("Skipping %s observance of timezone %q: %v", sub.Name, tz.tzid, err)
+				continue
+			}
+			tz.observances = append(tz.observances, obs)
+		}
+		if tz.tzid != "" && len(tz.observances) > 0 {
+			zones[tz.tzid] = tz
+		}
+	}
+	return zones
+}
+
+func parseICSObservance(comp *icsComponent) (icsObservance, error) {
+	var obs icsObservance
+
+	start, err := time.Parse(icsDateTimeLayout, comp.text("DTSTART"))
+	if err != nil {
+		return obs, fmt.Errorf("invalid DTSTART: %v", err)
+	}
+	obs.start = start
+
+	if obs.offsetFrom, err = parseICSOffset(comp.text("TZOFFSETFROM")); err != nil {
+		return obs, err
+	}
+	if obs.offsetTo, err = parseICSOffset(comp.text("TZOFFSETTO")); err != nil {
+		return obs, err
+	}
+
+	obs.rrule = comp.text("RRULE")
+	for _, p := range comp.props("RDATE") {
+		for _, v := range strings.Split(p.Value, ",") {
+			if t, err := time.Parse(icsDateTimeLayout, v); err == nil {
+				obs.rdates = append(obs.rdates, t)
+			}
+		}
+	}
+
+	return obs, nil
+}
+
+// parseICSOffset parses a UTC offset such as -0500 or +053000 into seconds
+func parseICSOffset(s string) (int, error) {
+	if len(s) != 5 && len(s) != 7 {
+		return 0, fmt.Errorf("invalid utc offset %q", s)
+	}
+
+	sign := 1
+	switch s[0] {
+	case '-':
+		sign = -1
+	case '+':
+	default:
+		return 0, fmt.Errorf("invalid utc offset %q", s)
+	}
+
+	hours, err1 := strconv.Atoi(s[1:3])
+	minutes, err2 := strconv.Atoi(s[3:5])
+	seconds := 0
+	var err3 error
+	if len(s) == 7 {
+		seconds, err3 = strconv.Atoi(s[5:7])
+	}
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, fmt.Errorf("invalid utc offset %q", s)
+	}
+
+	return sign * (hours*3600 + minutes*60 + seconds), nil
+}
+
+// offsetAt returns the UTC offset in effect at the given wall-clock time
+func (tz *icsTimezone) offsetAt(wall time.Time) int {
+	var latest time.Time
+	offset := tz.observances[0].offsetFrom
+	found := false
+
+	for _, obs := range tz.observances {
+		onset, ok := obs.lastOnset(wall)
+		if ok && (!found || onset.After(latest)) {
+			latest = onset
+			offset = obs.offsetTo
+			found = true
+		}
+	}
+
+	return offset
+}
+
+// lastOnset returns the most recent onset of the observance at or before wall
+func (obs icsObservance) lastOnset(wall time.Time) (time.Time, bool) {
+	if obs.start.After(wall) {
+		return time.Time{}, false
+	}
+
+	set := &recurrence.Set{Start: obs.start, RDates: obs.rdates}
+	if obs.rrule != "" {
+		rule, err := recurrence.ParseRule(obs.rrule, time.UTC)
+		if err != nil {
+			log.Printf("Ignoring timezone rule %q: %v", obs.rrule, err)
+		} else {
+			set.Rules = append(set.Rules, rule)
+		}
+	}
+
+	// Onsets are yearly, so the last year almost always holds the answer
+	onsets := set.Between(wall.AddDate(-1, 0, 0), wall.Add(time.Nanosecond))
+	if len(onsets) == 0 {
+		onsets = set.Between(obs.start, wall.Add(time.Nanosecond))
+	}
+	if len(onsets) == 0 {
+		return obs.start, true
+	}
+	return onsets[len(onsets)-1], true
+}
+
+// wallClock converts an instant into the zone's wall-clock time, labelled UTC
+func (tz *icsTimezone) wallClock(instant time.Time) time.Time {
+	approx := instant.UTC().Add(time.Duration(tz.offsetAt(instant.UTC())) * time.Second)
+	return instant.UTC().Add(time.Duration(tz.offsetAt(approx)) * time.Second)
+}
+
+// fromWallClock converts a UTC-labelled wall-clock time in the zone into an instant
+func (tz *icsTimezone) fromWallClock(wall time.Time) time.Time {
+	offset := tz.offsetAt(wall)
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, time.FixedZone(tz.tzid, offset))
+}
+
+// parseTime resolves a DATE or DATE-TIME property. IANA zone names are looked
+// up in the system tz database; other TZIDs (such as Outlook's "Eastern
+// Standard Time") use the calendar's VTIMEZONE definitions.
+func (zones icsTimezones) parseTime(p icsProperty, loc *time.Location) (icsTime, error) {
+	value := strings.TrimSpace(p.Value)
+
+	if strings.ToUpper(p.Params["VALUE"]) == "DATE" || len(value) == len(icsDateLayout) {
+		t, err := time.ParseInLocation(icsDateLayout, value, loc)
+		if err != nil {
+			return icsTime{}, err
+		}
+		return icsTime{Time: t, AllDay: true}, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icsDateTimeLayout, strings.TrimSuffix(value, "Z"))
+		if err != nil {
+			return icsTime{}, err
+		}
+		return icsTime{Time: t}, nil
+	}
+
+	tzid := strings.TrimPrefix(p.Params["TZID"], "/")
+	if tzid == "" {
+		t, err := time.ParseInLocation(icsDateTimeLayout, value, loc)
+		return icsTime{Time: t}, err
+	}
+
+	if zoneLoc, err := time.LoadLocation(tzid); err == nil {
+		t, err := time.ParseInLocation(icsDateTimeLayout, value, zoneLoc)
+		return icsTime{Time: t, TZID: tzid}, err
+	}
+
+	wall, err := time.Parse(icsDateTimeLayout, value)
+	if err != nil {
+		return icsTime{}, err
+	}
+
+	tz, ok := zones[tzid]
+	if !ok {
+		log.Printf("Unknown timezone %q, using %s", tzid, loc)
+		t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, loc)
+		return icsTime{Time: t}, nil
+	}
+
+	return icsTime{Time: tz.fromWallClock(wall), zone: tz}, nil
+}
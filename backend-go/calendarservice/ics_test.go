@@ -0,0 +1,319 @@
+package calendarservice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/retry"
+)
+
+const testICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Test//EN\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:Eastern Standard Time\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:16011104T020000\r\n" +
+	"RRULE:FREQ=YEARLY;BYDAY=1SU;BYMONTH=11\r\n" +
+	"TZOFFSETFROM:-0400\r\n" +
+	"TZOFFSETTO:-0500\r\n" +
+	"END:STANDARD\r\n" +
+	"BEGIN:DAYLIGHT\r\n" +
+	"DTSTART:16010311T020000\r\n" +
+	"RRULE:FREQ=YEARLY;BYDAY=2SU;BYMONTH=3\r\n" +
+	"TZOFFSETFROM:-0500\r\n" +
+	"TZOFFSETTO:-0400\r\n" +
+	"END:DAYLIGHT\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:winter@test\r\n" +
+	"SUMMARY:Winter concert\r\n" +
+	"DESCRIPTION:Bring a folding chair\\, and a\r\n" +
+	"  blanket\\nDoors open at 6\r\n" +
+	"DTSTART;TZID=Eastern Standard Time:20240110T190000\r\n" +
+	"DTEND;TZID=Eastern Standard Time:20240110T203000\r\n" +
+	"ORGANIZER;CN=Music Teacher:mailto:music@school.example.com\r\n" +
+	"ATTENDEE;CN=Emma;PARTSTAT=ACCEPTED:MAILTO:emma@example.com\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:summer@test\r\n" +
+	"SUMMARY:Summer game\r\n" +
+	"DTSTART;TZID=Eastern Standard Time:20240710T190000\r\n" +
+	"DURATION:PT2H\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:break@test\r\n" +
+	"SUMMARY:Winter break\r\n" +
+	"DTSTART;VALUE=DATE:20240108\r\n" +
+	"DTEND;VALUE=DATE:20240111\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:utc@test\r\n" +
+	"SUMMARY:Practice\r\n" +
+	"DTSTART:20240109T230000Z\r\n" +
+	"DTEND:20240110T000000Z\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"TRIGGER:-PT15M\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:cancelled@test\r\n" +
+	"SUMMARY:Cancelled game\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"DTSTART:20240109T180000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICSEvents(t *testing.T) {
+	cal, err := parseICS(strings.NewReader(testICS))
+	if err != nil {
+		t.Fatalf("Failed to parse ics: %v", err)
+	}
+
+	events := parseICSEvents(cal, time.UTC)
+	if len(events) != 5 {
+		t.Fatalf("Expected 5 events, got %d", len(events))
+	}
+
+	byUID := map[string]*icsEvent{}
+	for _, ev := range events {
+		byUID[ev.uid] = ev
+	}
+
+	winter := byUID["winter@test"]
+	if want := "Bring a folding chair, and a blanket\nDoors open at 6"; winter.description != want {
+		t.Errorf("Expected unfolded description %q, got %q", want, winter.description)
+	}
+	if want := time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC); !winter.start.Time.Equal(want) {
+		t.Errorf("Expected winter start %v (EST), got %v", want, winter.start.Time)
+	}
+
+	if converted := winter.toCalendarEvent(); converted.Organizer != "music@school.example.com" || strings.Join(converted.Attendees, ",") != "emma@example.com" {
+		t.Errorf("Expected the organizer and attendee addresses, got %q and %v", converted.Organizer, converted.Attendees)
+	}
+
+	summer := byUID["summer@test"]
+	if want := time.Date(2024, 7, 10, 23, 0, 0, 0, time.UTC); !summer.start.Time.Equal(want) {
+		t.Errorf("Expected summer start %v (EDT), got %v", want, summer.start.Time)
+	}
+	if got := summer.end.Time.Sub(summer.start.Time); got != 2*time.Hour {
+		t.Errorf("Expected summer duration 2h, got %v", got)
+	}
+
+	winterBreak := byUID["break@test"]
+	if !winterBreak.start.AllDay {
+		t.Error("Expected winter break to be an all-day event")
+	}
+	converted := winterBreak.toCalendarEvent()
+	if converted.Start.Date != "2024-01-08" || converted.End.Date != "2024-01-11" {
+		t.Errorf("Expected all-day dates 2024-01-08..2024-01-11, got %s..%s", converted.Start.Date, converted.End.Date)
+	}
+	if converted.Start.DateTime != "" {
+		t.Errorf("Expected no dateTime for all-day event, got %s", converted.Start.DateTime)
+	}
+}
+
+func TestParseICSSkipsMalformedLines(t *testing.T) {
+	cal, err := parseICS(strings.NewReader("BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:recital@test\r\n" +
+		"SUMMARY:Recital\r\n" +
+		"X-VENDOR-INFO;BROKENPARAM:value\r\n" +
+		"DTSTART:20240110T180000Z\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"))
+	if err != nil {
+		t.Fatalf("Expected the malformed line to be skipped, got %v", err)
+	}
+
+	events := parseICSEvents(cal, time.UTC)
+	if len(events) != 1 || events[0].summary != "Recital" || !events[0].start.Time.Equal(time.Date(2024, 1, 10, 18, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the recital with its start, got %+v", events)
+	}
+
+	// A broken component structure still fails
+	for _, data := range []string{
+		"BEGIN:VCALENDAR\r\nBEGIN;BROKEN:VEVENT\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if _, err := parseICS(strings.NewReader(data)); err == nil {
+			t.Errorf("Expected an error for %q", data)
+		}
+	}
+}
+
+func TestParseICSDuration(t *testing.T) {
+	testCases := []struct {
+		in   string
+		days int
+		d    time.Duration
+	}{
+		{"P1D", 1, 0},
+		{"PT1H30M", 0, 90 * time.Minute},
+		{"P1W", 7, 0},
+		{"-PT15M", 0, -15 * time.Minute},
+		{"P1DT12H", 1, 12 * time.Hour},
+	}
+
+	for _, tc := range testCases {
+		days, d, err := parseICSDuration(tc.in)
+		if err != nil {
+			t.Errorf("parseICSDuration(%q) returned error: %v", tc.in, err)
+			continue
+		}
+		if days != tc.days || d != tc.d {
+			t.Errorf("parseICSDuration(%q) = %d, %v; want %d, %v", tc.in, days, d, tc.days, tc.d)
+		}
+	}
+
+	if _, _, err := parseICSDuration("1H"); err == nil {
+		t.Error("Expected error for malformed duration")
+	}
+}
+
+func TestICSSourceFromURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/calendar")
+		w.Write([]byte(testICS))
+	}))
+	defer ts.Close()
+
+	src := &icsSource{
+		url:         ts.URL,
+		httpClient:  ts.Client(),
+		retryConfig: retry.DefaultConfig(),
+	}
+
+	timeMin := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	timeMax := time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)
+	events, err := src.Events(context.Background(), timeMin, timeMax)
+	if err != nil {
+		t.Fatalf("Failed to fetch events: %v", err)
+	}
+
+	// The summer event is outside the window and the cancelled one is dropped
+	var ids []string
+	for _, ev := range events {
+		ids = append(ids, ev.ID)
+	}
+	if want := "break@test,utc@test,winter@test"; strings.Join(ids, ",") != want {
+		t.Errorf("Expected events %s, got %s", want, strings.Join(ids, ","))
+	}
+}
+
+func TestICSSourceFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "school.ics")
+	if err := os.WriteFile(path, []byte(testICS), 0644); err != nil {
+		t.Fatalf("Failed to write ics file: %v", err)
+	}
+
+	src := &icsSource{path: path}
+
+	timeMin := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	timeMax := time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC)
+	events, err := src.Events(context.Background(), timeMin, timeMax)
+	if err != nil {
+		t.Fatalf("Failed to read events: %v", err)
+	}
+
+	if len(events) != 1 || events[0].Summary != "Summer game" {
+		t.Fatalf("Expected only the summer game, got %+v", events)
+	}
+	if want := "2024-07-10T19:00:00-04:00"; events[0].Start.DateTime != want {
+		t.Errorf("Expected start %s, got %s", want, events[0].Start.DateTime)
+	}
+}
+
+func TestICSSourceNonTransientError(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	src := &icsSource{
+		url:         ts.URL,
+		httpClient:  ts.Client(),
+		retryConfig: retry.DefaultConfig(),
+	}
+
+	if _, err := src.Events(context.Background(), time.Now(), time.Now().Add(time.Hour)); err == nil {
+		t.Fatal("Expected error for missing feed")
+	}
+	if requests != 1 {
+		t.Errorf("Expected a single request for a non-transient error, got %d", requests)
+	}
+}
+
+const testRecurringICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:practice@test\r\n" +
+	"SUMMARY:Soccer practice\r\n" +
+	"DTSTART;TZID=America/New_York:20240301T170000\r\n" +
+	"DTEND;TZID=America/New_York:20240301T183000\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=FR\r\n" +
+	"EXDATE;TZID=America/New_York:20240315T170000\r\n" +
+	"RDATE;TZID=America/New_York:20240313T170000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:practice@test\r\n" +
+	"SUMMARY:Soccer practice (moved)\r\n" +
+	"RECURRENCE-ID;TZID=America/New_York:20240308T170000\r\n" +
+	"DTSTART;TZID=America/New_York:20240309T100000\r\n" +
+	"DTEND;TZID=America/New_York:20240309T113000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:trash@test\r\n" +
+	"SUMMARY:Trash\r\n" +
+	"DTSTART;VALUE=DATE:20240305\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=TU,FR\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestExpandICSEvents(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone America/New_York not available: %v", err)
+	}
+
+	cal, err := parseICS(strings.NewReader(testRecurringICS))
+	if err != nil {
+		t.Fatalf("Failed to parse ics: %v", err)
+	}
+
+	// The window spans the March 10 DST transition
+	timeMin := time.Date(2024, 3, 3, 0, 0, 0, 0, ny)
+	timeMax := time.Date(2024, 3, 17, 0, 0, 0, 0, ny)
+	events := expandICSEvents(parseICSEvents(cal, ny), timeMin, timeMax)
+
+	var got []string
+	for _, ev := range events {
+		converted := ev.toCalendarEvent()
+		start := converted.Start.DateTime
+		if start == "" {
+			start = converted.Start.Date
+		}
+		got = append(got, converted.ID+" "+start)
+	}
+
+	expected := []string{
+		"trash@test_20240305 2024-03-05",
+		"trash@test_20240308 2024-03-08",
+		"practice@test_20240308T220000Z 2024-03-09T10:00:00-05:00",
+		"trash@test_20240312 2024-03-12",
+		"practice@test_20240313T210000Z 2024-03-13T17:00:00-04:00",
+		"trash@test_20240315 2024-03-15",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected instances:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
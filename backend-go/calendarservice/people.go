@@ -0,0 +1,135 @@
+package calendarservice
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+// peopleTagger tags events with the family members they belong to. A nil
+// tagger leaves every event untagged.
+type peopleTagger struct {
+	people []personMatcher
+}
+
+// personMatcher is a validated models.Person
+type personMatcher struct {
+	tag       models.PersonTag
+	emails    map[string]bool
+	calendars map[string]bool
+	prefix    *regexp.Regexp
+}
+
+// newPeopleTagger validates the configured people, or returns nil when there
+// are none
+func newPeopleTagger(people []models.Person) (*peopleTagger, error) {
+	if len(people) == 0 {
+		return nil, nil
+	}
+
+	tagger := &peopleTagger{}
+	names := map[string]bool{}
+	for i, p := range people {
+		if p.Name == "" {
+			return nil, fmt.Errorf("person %d is missing a name", i+1)
+		}
+		if names[strings.ToLower(p.Name)] {
+			return nil, fmt.Errorf("person %q is listed twice", p.Name)
+		}
+		names[strings.ToLower(p.Name)] = true
+
+		matcher := personMatcher{
+			tag:       models.PersonTag{Name: p.Name, Initials: p.Initials, Color: p.Color},
+			emails:    valueSet(p.Emails),
+			calendars: map[string]bool{},
+			prefix:    summaryPrefixPattern(append([]string{p.Name}, p.Aliases...)),
+		}
+		for _, calendar := range p.Calendars {
+			matcher.calendars[calendar] = true
+		}
+		tagger.people = append(tagger.people, matcher)
+	}
+
+	return tagger, nil
+}
+
+// summaryPrefixPattern matches summaries starting with one of names followed
+// by a colon or dash, or in brackets: "Emma: Soccer", "Emma - Soccer",
+// "[Emma] Soccer"
+func summaryPrefixPattern(names []string) *regexp.Regexp {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			quoted = append(quoted, regexp.QuoteMeta(name))
+		}
+	}
+	alternatives := strings.Join(quoted, "|")
+	return regexp.MustCompile(`(?i)^\s*(?:\[(?:` + alternatives + `)\]|(?:` + alternatives + `)\s*[:-])`)
+}
+
+// tag sets the people of an event from its addresses, its summary and
+// calendar, the name of the calendar it is on
+func (t *peopleTagger) tag(event *models.CalendarEvent, calendar string) {
+	if t == nil {
+		return
+	}
+
+	event.People = nil
+	for _, person := range t.people {
+		if person.matches(*event, calendar) {
+			event.People = append(event.People, person.tag)
+		}
+	}
+}
+
+func (p personMatcher) matches(event models.CalendarEvent, calendar string) bool {
+	if p.calendars[calendar] || p.prefix.MatchString(event.Summary) {
+		return true
+	}
+	if p.emails == nil {
+		return false
+	}
+	if p.emails[strings.ToLower(event.Organizer)] {
+		return true
+	}
+	for _, attendee := range event.Attendees {
+		if p.emails[strings.ToLower(attendee)] {
+			return true
+		}
+	}
+	return false
+}
+
+// forPerson returns the snapshot narrowed to the events of a configured
+// person, matched by name or initials. An empty name returns the snapshot
+// unchanged.
+func (c *calendarSnapshot) forPerson(name string) (*calendarSnapshot, error) {
+	if name == "" {
+		return c, nil
+	}
+
+	var person *models.Person
+	for i, p := range c.config.People {
+		if strings.EqualFold(p.Name, name) || (p.Initials != "" && strings.EqualFold(p.Initials, name)) {
+			person = &c.config.People[i]
+			break
+		}
+	}
+	if person == nil {
+		return nil, fmt.Errorf("unknown person %q", name)
+	}
+
+	narrowed := *c
+	narrowed.events = []models.CalendarEvent{}
+	for _, event := range c.events {
+		for _, tag := range event.People {
+			if tag.Name == person.Name {
+				narrowed.events = append(narrowed.events, event)
+				break
+			}
+		}
+	}
+	return &narrowed, nil
+}
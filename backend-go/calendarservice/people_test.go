@@ -0,0 +1,209 @@
+package calendarservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+var testPeople = []models.Person{
+	{Name: "Emma", Initials: "E", Color: "pink", Emails: []string{"emma@example.com"}, Aliases: []string{"Em"}},
+	{Name: "Liam", Initials: "L", Color: "green", Calendars: []string{"liam@example.com"}},
+	{Name: "Dad", Initials: "D", Color: "blue", Emails: []string{"dad@example.com"}},
+}
+
+func personNames(event models.CalendarEvent) string {
+	var names []string
+	for _, person := range event.People {
+		names = append(names, person.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestPeopleTagger(t *testing.T) {
+	tagger, err := newPeopleTagger(testPeople)
+	if err != nil {
+		t.Fatalf("Failed to configure people: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		event    models.CalendarEvent
+		calendar string
+		expected string
+	}{
+		{"Attendee", models.CalendarEvent{Summary: "Recital", Attendees: []string{"EMMA@example.com"}}, "family", "Emma"},
+		{"Organizer", models.CalendarEvent{Summary: "Standup", Organizer: "dad@example.com"}, "family", "Dad"},
+		{"Owning calendar", models.CalendarEvent{Summary: "Swim"}, "liam@example.com", "Liam"},
+		{"Summary prefix", models.CalendarEvent{Summary: "Emma: Soccer"}, "family", "Emma"},
+		{"Alias in brackets", models.CalendarEvent{Summary: "[em] Piano"}, "family", "Emma"},
+		{"Dash prefix", models.CalendarEvent{Summary: "Dad - Haircut"}, "family", "Dad"},
+		{"Name inside the summary", models.CalendarEvent{Summary: "Pick up Emma"}, "family", ""},
+		{"Several people", models.CalendarEvent{Summary: "Liam: Game", Attendees: []string{"dad@example.com"}}, "family", "Liam,Dad"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			event := tc.event
+			tagger.tag(&event, tc.calendar)
+			if got := personNames(event); got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewPeopleTaggerErrors(t *testing.T) {
+	if tagger, err := newPeopleTagger(nil); tagger != nil || err != nil {
+		t.Errorf("Expected no tagger without people, got %v, %v", tagger, err)
+	}
+	if _, err := newPeopleTagger([]models.Person{{Initials: "E"}}); err == nil {
+		t.Error("Expected an error for a person without a name")
+	}
+	if _, err := newPeopleTagger([]models.Person{{Name: "Emma"}, {Name: "emma"}}); err == nil {
+		t.Error("Expected an error for a person listed twice")
+	}
+}
+
+func TestMergeDuplicatesKeepsPeopleOfEveryCopy(t *testing.T) {
+	start := models.EventTime{DateTime: "2024-01-10T18:00:00Z"}
+	events := []models.CalendarEvent{
+		{ID: "a", ICalUID: "game@example.com", Start: start, People: []models.PersonTag{{Name: "Dad"}}},
+		{ID: "b", ICalUID: "game@example.com", Start: start, People: []models.PersonTag{{Name: "Liam"}, {Name: "Dad"}}},
+	}
+
+	merged := mergeDuplicates(events, duplicatePolicyFirst, time.UTC)
+
+	if len(merged) != 1 || personNames(merged[0]) != "Dad,Liam" {
+		t.Errorf("Expected one event for Dad and Liam, got %+v", merged)
+	}
+}
+
+func TestFetchAllTagsPeopleOfPrivateCalendars(t *testing.T) {
+	tagger, err := newPeopleTagger(testPeople)
+	if err != nil {
+		t.Fatalf("Failed to configure people: %v", err)
+	}
+	svc := &Service{
+		people: tagger,
+		sources: []calendarSource{
+			{
+				config: models.CalendarEntry{Type: sourceTypeICS, URL: "https://example.com/work.ics", Privacy: privacyTitle},
+				source: &fakeSource{events: []models.CalendarEvent{
+					{ID: "1", Summary: "Standup", Organizer: "dad@example.com", Start: models.EventTime{Date: "2024-01-10"}, End: models.EventTime{Date: "2024-01-11"}},
+					{ID: "2", Summary: "Recital", Attendees: []string{"emma@example.com"}, Start: models.EventTime{Date: "2024-01-10"}, End: models.EventTime{Date: "2024-01-11"}},
+				}},
+			},
+			{
+				config: models.CalendarEntry{CalendarID: "liam@example.com", Type: sourceTypeICS, Privacy: privacyBusy},
+				source: &fakeSource{events: []models.CalendarEvent{
+					{ID: "3", Summary: "Emma: Tutoring", Start: models.EventTime{Date: "2024-01-11"}, End: models.EventTime{Date: "2024-01-12"}},
+				}},
+			},
+		},
+		now: time.Now,
+	}
+
+	timeMin := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	events, _ := svc.fetchAll(context.Background(), timeMin, timeMin.AddDate(0, 0, 7))
+
+	var tagged []string
+	for _, event := range events {
+		if event.Organizer != "" || event.Attendees != nil {
+			t.Errorf("Expected the addresses of %s to be stripped", event.Summary)
+		}
+		tagged = append(tagged, event.Summary+":"+personNames(event))
+	}
+	if got := strings.Join(tagged, " "); got != "Standup:Dad Recital:Emma Busy:Liam" {
+		t.Errorf("Expected people matched before stripping, and busy blocks by calendar only, got %s", got)
+	}
+}
+
+func TestHandleGetCalendarForPerson(t *testing.T) {
+	tagger, err := newPeopleTagger(testPeople)
+	if err != nil {
+		t.Fatalf("Failed to configure people: %v", err)
+	}
+	svc := &Service{
+		calendarConfig: models.CalendarConfig{NumberOfWeeks: 1, People: testPeople},
+		people:         tagger,
+		sources: []calendarSource{
+			{
+				config: models.CalendarEntry{CalendarID: "family", Color: "blue"},
+				source: &fakeSource{events: []models.CalendarEvent{
+					{ID: "1", Summary: "Emma: Soccer", Start: models.EventTime{Date: "2024-01-10"}, End: models.EventTime{Date: "2024-01-11"}},
+					{ID: "2", Summary: "Dentist", Start: models.EventTime{Date: "2024-01-10"}, End: models.EventTime{Date: "2024-01-11"}},
+				}},
+			},
+			{
+				config: models.CalendarEntry{CalendarID: "liam@example.com", Color: "green"},
+				source: &fakeSource{events: []models.CalendarEvent{
+					{ID: "3", Summary: "Swim", Start: models.EventTime{Date: "2024-01-11"}, End: models.EventTime{Date: "2024-01-12"}},
+				}},
+			},
+		},
+		now: func() time.Time {
+			return time.Date(2024, 1, 10, 12, 0, 0, 0, time.Local)
+		},
+	}
+
+	testCases := []struct {
+		query    string
+		code     int
+		expected string
+	}{
+		{"", http.StatusOK, "1,2,3"},
+		{"?person=emma", http.StatusOK, "1"},
+		{"?person=L", http.StatusOK, "3"},
+		{"?person=Dad", http.StatusOK, ""},
+		{"?person=Grandma", http.StatusBadRequest, ""},
+	}
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		svc.HandleGetCalendar(w, httptest.NewRequest(http.MethodGet, "/api/calendar"+tc.query, nil))
+
+		if w.Code != tc.code {
+			t.Errorf("%s: expected status code %d, got %d", tc.query, tc.code, w.Code)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+
+		var response struct {
+			Events []models.CalendarEvent `json:"events"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		var ids []string
+		for _, event := range response.Events {
+			ids = append(ids, event.ID)
+		}
+		if got := strings.Join(ids, ","); got != tc.expected {
+			t.Errorf("%s: expected events %q, got %q", tc.query, tc.expected, got)
+		}
+	}
+
+	// The grid is narrowed the same way
+	w := httptest.NewRecorder()
+	svc.HandleGetCalendarGrid(w, httptest.NewRequest(http.MethodGet, "/api/calendar/grid?person=Emma", nil))
+	var grid models.CalendarData
+	if err := json.NewDecoder(w.Body).Decode(&grid); err != nil {
+		t.Fatalf("Failed to decode grid: %v", err)
+	}
+	for _, day := range grid.Events {
+		for _, event := range day.Events {
+			if event.ID != "1" {
+				t.Errorf("Expected only Emma's events in the grid, got %s on %s", event.ID, day.Date)
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package calendarservice
+
+import (
+	"context"
+	"time"
+)
+
+// Start refreshes the calendars in the background, immediately and then every
+// refresh interval, until ctx is done. Handlers only serve the resulting
+// snapshot, so page loads never wait on the calendar providers.
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		s.refreshWithTimeout(ctx)
+
+		ticker := time.NewTicker(s.refreshInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.refreshWithTimeout(ctx)
+			}
+		}
+	}()
+}
+
+func (s *Service) refreshWithTimeout(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, calendarRequestTimeout)
+	defer cancel()
+
+	s.refresh(ctx)
+}
+
+func (s *Service) refreshInterval() time.Duration {
+	if minutes := s.calendarConfig.RefreshIntervalMinutes; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultRefreshInterval
+}
@@ -0,0 +1,65 @@
+package calendarservice
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+// Values accepted in CalendarEntry.Privacy
+const (
+	privacyFull  = "full"
+	privacyTitle = "title"
+	privacyBusy  = "busy"
+)
+
+// Summary of the events of busy-only calendars
+const busySummary = "Busy"
+
+// validatePrivacy rejects unknown CalendarEntry.Privacy values
+func validatePrivacy(privacy string) error {
+	switch privacy {
+	case "", privacyFull, privacyTitle, privacyBusy:
+		return nil
+	}
+	return fmt.Errorf("unknown privacy %q", privacy)
+}
+
+// applyPrivacy strips what the privacy level of a calendar doesn't allow to
+// be shown. It runs before events are cached or served, so stripped details
+// never leave the backend.
+func applyPrivacy(events []models.CalendarEvent, privacy string) []models.CalendarEvent {
+	if privacy == "" || privacy == privacyFull {
+		return events
+	}
+
+	stripped := make([]models.CalendarEvent, len(events))
+	for i, event := range events {
+		event.Description = ""
+		event.Location = ""
+		event.Organizer = ""
+		event.Attendees = nil
+		if privacy == privacyBusy {
+			event = busyEvent(event.ID, event.Start, event.End)
+		}
+		stripped[i] = event
+	}
+	return stripped
+}
+
+// busyEvent is an anonymous block of busy time. Its ID is derived from key
+// so it stays stable across refreshes without revealing anything.
+func busyEvent(key string, start, end models.EventTime) models.CalendarEvent {
+	sum := sha256.Sum256([]byte(key))
+	return models.CalendarEvent{
+		ID:           "busy-" + hex.EncodeToString(sum[:8]),
+		Summary:      busySummary,
+		Start:        start,
+		End:          end,
+		Transparency: "opaque",
+		Visibility:   "default",
+		EventType:    "default",
+	}
+}
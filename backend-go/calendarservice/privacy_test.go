@@ -0,0 +1,149 @@
+package calendarservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+	"github.com/coreyk/piinky/backend-go/snapshot"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+func TestApplyPrivacy(t *testing.T) {
+	events := []models.CalendarEvent{{
+		ID:          "review@work",
+		Summary:     "Performance review",
+		Description: "Agenda: salary",
+		Location:    "Room 4",
+		Organizer:   "boss@work.example.com",
+		Attendees:   []string{"me@work.example.com"},
+		ICalUID:     "review@work",
+		Start:       models.EventTime{DateTime: "2024-01-10T09:00:00Z"},
+		End:         models.EventTime{DateTime: "2024-01-10T10:00:00Z"},
+	}}
+
+	full := applyPrivacy(events, privacyFull)
+	if full[0].Description != "Agenda: salary" {
+		t.Errorf("Expected full details, got %+v", full[0])
+	}
+
+	title := applyPrivacy(events, privacyTitle)[0]
+	if title.Summary != "Performance review" || title.Description != "" || title.Location != "" || title.Organizer != "" || title.Attendees != nil {
+		t.Errorf("Expected only the title, got %+v", title)
+	}
+
+	busy := applyPrivacy(events, privacyBusy)[0]
+	if busy.Summary != busySummary || busy.Description != "" || busy.ICalUID != "" || strings.Contains(busy.ID, "review") {
+		t.Errorf("Expected an anonymous busy block, got %+v", busy)
+	}
+	if busy.Start != events[0].Start || busy.End != events[0].End {
+		t.Errorf("Expected the busy block to keep the event's times, got %+v", busy)
+	}
+
+	if events[0].Description != "Agenda: salary" {
+		t.Error("Expected the fetched events to be left untouched")
+	}
+}
+
+func TestFetchAllStripsPrivateDetailsBeforeCaching(t *testing.T) {
+	dir := t.TempDir()
+	store, err := snapshot.NewStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create snapshot store: %v", err)
+	}
+
+	svc := &Service{
+		snapshots: store,
+		sources: []calendarSource{{
+			config: models.CalendarEntry{Type: sourceTypeICS, URL: "https://example.com/work.ics", Color: "gray", Privacy: privacyTitle},
+			source: &fakeSource{events: []models.CalendarEvent{{
+				ID:          "1",
+				Summary:     "Interview",
+				Description: "Candidate: Jane",
+				Location:    "Room 4",
+				Attendees:   []string{"jane@example.com"},
+			}}},
+		}},
+		now: time.Now,
+	}
+
+	events, _ := svc.fetchAll(context.Background(), time.Now(), time.Now().Add(time.Hour))
+	data, err := json.Marshal(events)
+	if err != nil {
+		t.Fatalf("Failed to encode events: %v", err)
+	}
+	if strings.Contains(string(data), "Jane") || strings.Contains(string(data), "Room 4") {
+		t.Errorf("Expected private details stripped from the response, got %s", data)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Expected a snapshot to be saved, got %v, %v", files, err)
+	}
+	for _, file := range files {
+		cached, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read snapshot: %v", err)
+		}
+		if strings.Contains(string(cached), "Jane") || strings.Contains(string(cached), "Room 4") {
+			t.Errorf("Expected private details stripped from the snapshot, got %s", cached)
+		}
+	}
+}
+
+func TestNewCalendarSourcesPrivacy(t *testing.T) {
+	if _, err := newCalendarSources([]models.CalendarEntry{{Type: sourceTypeICS, URL: "https://example.com/work.ics", Privacy: "secret"}}, nil, nil, retry.DefaultConfig(), nil, time.Now); err == nil {
+		t.Error("Expected an error for an unknown privacy level")
+	}
+}
+
+func TestGoogleSyncStateNotSavedForPrivateCalendars(t *testing.T) {
+	event := newTimedEvent("review", "Review", "2024-01-11T16:00:00Z", "2024-01-11T17:00:00Z")
+	event.Description = "Agenda: salary"
+	fake := &fakeGoogleCalendar{fullSync: [][]*calendar.Event{{event}}, nextToken: "token-1"}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	store, err := snapshot.NewStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create snapshot store: %v", err)
+	}
+
+	// State saved before the calendar was made private is removed
+	full := newTestGoogleSource(t, ts, store)
+	if _, err := full.Events(context.Background(), time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Failed to fetch events: %v", err)
+	}
+
+	sources, err := newCalendarSources([]models.CalendarEntry{{CalendarID: "family", Privacy: privacyTitle}}, func() (*calendar.Service, error) {
+		return newTestGoogleSource(t, ts, nil).calendarSvc, nil
+	}, nil, retry.DefaultConfig(), store, time.Now)
+	if err != nil {
+		t.Fatalf("Failed to create sources: %v", err)
+	}
+	if _, err := sources[0].source.Events(context.Background(), time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Failed to fetch events: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatalf("Failed to list snapshots: %v", err)
+	}
+	for _, file := range files {
+		saved, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read snapshot: %v", err)
+		}
+		if strings.Contains(string(saved), "salary") {
+			t.Errorf("Expected no private details on disk, got %s in %s", saved, file)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package calendarservice
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+// Layout of dates in pickup schedules
+const scheduleDateLayout = "2006-01-02"
+
+// Values accepted in PickupSchedule.HolidayShift
+const (
+	holidayShiftNextDay    = "next_day"
+	holidayShiftRestOfWeek = "rest_of_week"
+)
+
+// scheduleSource synthesizes all-day trash and recycling events from pickup
+// rules, without any external calendar
+type scheduleSource struct {
+	pickups    []pickupRule
+	holidays   map[string]bool
+	restOfWeek bool
+	skipDates  map[string]bool
+}
+
+// pickupRule is a validated models.PickupRule
+type pickupRule struct {
+	summary    string
+	weekdays   map[time.Weekday]bool
+	everyWeeks int
+	anchorWeek int
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+func newScheduleSource(cfg *models.PickupSchedule) (*scheduleSource, error) {
+	if cfg == nil || len(cfg.Pickups) == 0 {
+		return nil, fmt.Errorf("schedule calendar entry has no pickups")
+	}
+
+	src := &scheduleSource{
+		holidays:  map[string]bool{},
+		skipDates: map[string]bool{},
+	}
+
+	switch cfg.HolidayShift {
+	case "", holidayShiftNextDay:
+	case holidayShiftRestOfWeek:
+		src.restOfWeek = true
+	default:
+		return nil, fmt.Errorf("unknown holiday_shift %q", cfg.HolidayShift)
+	}
+
+	for _, d := range cfg.Holidays {
+		if _, err := time.Parse(scheduleDateLayout, d); err != nil {
+			return nil, fmt.Errorf("invalid holiday %q: %v", d, err)
+		}
+		src.holidays[d] = true
+	}
+	for _, d := range cfg.SkipDates {
+		if _, err := time.Parse(scheduleDateLayout, d); err != nil {
+			return nil, fmt.Errorf("invalid skip date %q: %v", d, err)
+		}
+		src.skipDates[d] = true
+	}
+
+	for _, p := range cfg.Pickups {
+		rule := pickupRule{
+			summary:    p.Summary,
+			weekdays:   map[time.Weekday]bool{},
+			everyWeeks: p.EveryWeeks,
+		}
+		if rule.summary == "" {
+			return nil, fmt.Errorf("pickup is missing a summary")
+		}
+		if len(p.Weekdays) == 0 {
+			return nil, fmt.Errorf("pickup %q has no weekdays", p.Summary)
+		}
+		for _, name := range p.Weekdays {
+			wd, ok := parseWeekdayName(name)
+			if !ok {
+				return nil, fmt.Errorf("pickup %q has unknown weekday %q", p.Summary, name)
+			}
+			rule.weekdays[wd] = true
+		}
+
+		if rule.everyWeeks <= 1 {
+			rule.everyWeeks = 1
+		} else {
+			if p.Anchor == "" {
+				return nil, fmt.Errorf("pickup %q repeats every %d weeks but has no anchor date", p.Summary, p.EveryWeeks)
+			}
+			anchor, err := time.Parse(scheduleDateLayout, p.Anchor)
+			if err != nil {
+				return nil, fmt.Errorf("pickup %q has invalid anchor %q: %v", p.Summary, p.Anchor, err)
+			}
+			rule.anchorWeek = weekNumber(anchor)
+		}
+
+		src.pickups = append(src.pickups, rule)
+	}
+
+	return src, nil
+}
+
+// parseWeekdayName accepts full or three-letter English weekday names
+func parseWeekdayName(name string) (time.Weekday, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if wd, ok := weekdayNames[name]; ok {
+		return wd, true
+	}
+	for full, wd := range weekdayNames {
+		if len(name) == 3 && strings.HasPrefix(full, name) {
+			return wd, true
+		}
+	}
+	return 0, false
+}
+
+// weekNumber numbers Sunday-to-Saturday weeks consecutively
+func weekNumber(date time.Time) int {
+	days := int(time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+	// 1970-01-01 was a Thursday, so shift to count weeks from Sunday
+	return (days + 4) / 7
+}
+
+func (s *scheduleSource) Events(ctx context.Context, timeMin, timeMax time.Time) ([]models.CalendarEvent, error) {
+	loc := timeMin.Location()
+
+	// Start a week early so pickups shifted into the window by a holiday are kept
+	first := time.Date(timeMin.Year(), timeMin.Month(), timeMin.Day()-7, 0, 0, 0, 0, loc)
+
+	var events []models.CalendarEvent
+	seen := map[string]bool{}
+	for day := first; day.Before(timeMax); day = day.AddDate(0, 0, 1) {
+		for _, rule := range s.pickups {
+			if !rule.occursOn(day) || s.skipDates[day.Format(scheduleDateLayout)] {
+				continue
+			}
+
+			date, ok := s.shiftedDate(day)
+			if !ok {
+				continue
+			}
+			end := date.AddDate(0, 0, 1)
+			if !date.Before(timeMax) || !end.After(timeMin) {
+				continue
+			}
+
+			// A pickup shifted onto a day with its own pickup of the same
+			// rule is a single pickup
+			id := fmt.Sprintf("pickup-%s-%s", strings.ToLower(strings.ReplaceAll(rule.summary, " ", "-")), date.Format(icsDateLayout))
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			events = append(events, models.CalendarEvent{
+				ID:      id,
+				Summary: rule.summary,
+				Start:   models.EventTime{Date: date.Format(scheduleDateLayout)},
+				End:     models.EventTime{Date: end.Format(scheduleDateLayout)},
+			})
+		}
+	}
+
+	return events, nil
+}
+
+// occursOn reports whether the rule schedules a pickup on day
+func (r pickupRule) occursOn(day time.Time) bool {
+	if !r.weekdays[day.Weekday()] {
+		return false
+	}
+	diff := weekNumber(day) - r.anchorWeek
+	return diff%r.everyWeeks == 0
+}
+
+// shiftedDate returns the date a pickup scheduled on day takes place. A
+// pickup shifted onto another holiday moves on again, and one shifted onto a
+// skip date is dropped, reported by false.
+func (s *scheduleSource) shiftedDate(day time.Time) (time.Time, bool) {
+	date := day.AddDate(0, 0, s.holidayShift(day))
+	for date.After(day) && s.holidays[date.Format(scheduleDateLayout)] {
+		date = date.AddDate(0, 0, 1)
+	}
+	if date.After(day) && s.skipDates[date.Format(scheduleDateLayout)] {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// holidayShift returns how many days a pickup scheduled on day moves because
+// of holidays
+func (s *scheduleSource) holidayShift(day time.Time) int {
+	if s.holidays[day.Format(scheduleDateLayout)] {
+		return 1
+	}
+	if !s.restOfWeek {
+		return 0
+	}
+
+	// A holiday earlier in the same week delays the rest of the week's pickups
+	for d := day.AddDate(0, 0, -int(day.Weekday())); d.Before(day); d = d.AddDate(0, 0, 1) {
+		if s.holidays[d.Format(scheduleDateLayout)] {
+			return 1
+		}
+	}
+	return 0
+}
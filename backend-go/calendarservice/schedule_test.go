@@ -0,0 +1,165 @@
+package calendarservice
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+func testPickupSchedule() *models.PickupSchedule {
+	return &models.PickupSchedule{
+		Pickups: []models.PickupRule{
+			{Summary: "Trash", Weekdays: []string{"Tuesday", "fri"}},
+			{Summary: "Cardboard", Weekdays: []string{"wednesday"}, EveryWeeks: 2, Anchor: "2024-01-03"},
+			{Summary: "Cans", Weekdays: []string{"wednesday"}, EveryWeeks: 2, Anchor: "2024-01-10"},
+		},
+		Holidays:  []string{"2024-01-01"},
+		SkipDates: []string{"2024-01-12"},
+	}
+}
+
+func TestScheduleSourceEvents(t *testing.T) {
+	testCases := []struct {
+		name         string
+		holidayShift string
+		expected     []string
+	}{
+		{
+			name: "Holiday only moves pickups on the holiday",
+			expected: []string{
+				"Trash 2024-01-02",
+				"Cardboard 2024-01-03",
+				"Trash 2024-01-05",
+				"Trash 2024-01-09",
+				"Cans 2024-01-10",
+			},
+		},
+		{
+			name:         "Holiday moves the rest of the week",
+			holidayShift: "rest_of_week",
+			expected: []string{
+				"Trash 2024-01-03",
+				"Cardboard 2024-01-04",
+				"Trash 2024-01-06",
+				"Trash 2024-01-09",
+				"Cans 2024-01-10",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testPickupSchedule()
+			cfg.HolidayShift = tc.holidayShift
+			src, err := newScheduleSource(cfg)
+			if err != nil {
+				t.Fatalf("Failed to create schedule source: %v", err)
+			}
+
+			timeMin := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			timeMax := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+			events, err := src.Events(context.Background(), timeMin, timeMax)
+			if err != nil {
+				t.Fatalf("Failed to generate events: %v", err)
+			}
+
+			var got []string
+			for _, ev := range events {
+				got = append(got, ev.Summary+" "+ev.Start.Date)
+				if ev.Start.DateTime != "" {
+					t.Errorf("Expected an all-day event, got dateTime %s", ev.Start.DateTime)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.expected, "\n") {
+				t.Errorf("Expected pickups:\n%s\ngot:\n%s", strings.Join(tc.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestScheduleSourceShiftedPickups(t *testing.T) {
+	testCases := []struct {
+		name      string
+		weekdays  []string
+		holidays  []string
+		skipDates []string
+		expected  []string
+	}{
+		{
+			name:     "Pickup shifted onto the next pickup day is a single pickup",
+			weekdays: []string{"monday", "tuesday"},
+			holidays: []string{"2024-01-08"},
+			expected: []string{"Trash 2024-01-09"},
+		},
+		{
+			name:     "Pickup shifted onto another holiday moves on",
+			weekdays: []string{"monday"},
+			holidays: []string{"2024-01-08", "2024-01-09"},
+			expected: []string{"Trash 2024-01-10"},
+		},
+		{
+			name:      "Pickup shifted onto a skip date is dropped",
+			weekdays:  []string{"monday"},
+			holidays:  []string{"2024-01-08"},
+			skipDates: []string{"2024-01-09"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := newScheduleSource(&models.PickupSchedule{
+				Pickups:   []models.PickupRule{{Summary: "Trash", Weekdays: tc.weekdays}},
+				Holidays:  tc.holidays,
+				SkipDates: tc.skipDates,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create schedule source: %v", err)
+			}
+
+			timeMin := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+			timeMax := time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)
+			events, err := src.Events(context.Background(), timeMin, timeMax)
+			if err != nil {
+				t.Fatalf("Failed to generate events: %v", err)
+			}
+
+			var got []string
+			ids := map[string]bool{}
+			for _, ev := range events {
+				got = append(got, ev.Summary+" "+ev.Start.Date)
+				if ids[ev.ID] {
+					t.Errorf("Expected unique event IDs, got %s twice", ev.ID)
+				}
+				ids[ev.ID] = true
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.expected, "\n") {
+				t.Errorf("Expected pickups:\n%s\ngot:\n%s", strings.Join(tc.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestNewScheduleSourceErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(cfg *models.PickupSchedule)
+	}{
+		{"Unknown weekday", func(cfg *models.PickupSchedule) { cfg.Pickups[0].Weekdays = []string{"Funday"} }},
+		{"Alternating without anchor", func(cfg *models.PickupSchedule) { cfg.Pickups[1].Anchor = "" }},
+		{"Invalid holiday", func(cfg *models.PickupSchedule) { cfg.Holidays = []string{"01/01/2024"} }},
+		{"Unknown holiday shift", func(cfg *models.PickupSchedule) { cfg.HolidayShift = "next_week" }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testPickupSchedule()
+			tc.modify(cfg)
+			if _, err := newScheduleSource(cfg); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
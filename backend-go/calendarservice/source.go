@@ -0,0 +1,152 @@
+package calendarservice
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+	"github.com/coreyk/piinky/backend-go/snapshot"
+	calendar "google.golang.org/api/calendar/v3"
+)
+
+// Calendar source types accepted in CalendarEntry.Type
+const (
+	sourceTypeGoogle   = "google"
+	sourceTypeICS      = "ics"
+	sourceTypeCalDAV   = "caldav"
+	sourceTypeSchedule = "schedule"
+)
+
+// Source provides the events of a single configured calendar
+type Source interface {
+	// Events returns the events overlapping [timeMin, timeMax)
+	Events(ctx context.Context, timeMin, timeMax time.Time) ([]models.CalendarEvent, error)
+}
+
+// calendarSource pairs a configured calendar with the Source that fetches it
+type calendarSource struct {
+	config models.CalendarEntry
+	source Source
+	filter *eventFilter
+}
+
+// name returns a human-readable identifier for logs and error messages
+func (c calendarSource) name() string {
+	switch {
+	case c.config.CalendarID != "":
+		return c.config.CalendarID
+	case c.config.URL != "":
+		return c.config.URL
+	case c.config.Type == sourceTypeSchedule:
+		return "pickup schedule"
+	default:
+		return c.config.Path
+	}
+}
+
+// newCalendarSources builds a Source for every configured calendar. The Google
+// client is only created when at least one Google calendar is configured, and
+// Google sync state is persisted in snapshots, stamped with the time from now.
+func newCalendarSources(entries []models.CalendarEntry, newGoogleSvc func() (*calendar.Service, error), httpClient HTTPClient, retryConfig retry.Config, snapshots *snapshot.Store, now func() time.Time) ([]calendarSource, error) {
+	var googleSvc *calendar.Service
+	var colors *googleColors
+	sources := make([]calendarSource, 0, len(entries))
+
+	for _, entry := range entries {
+		if err := validatePrivacy(entry.Privacy); err != nil {
+			return nil, err
+		}
+
+		var source Source
+		switch entry.Type {
+		case "", sourceTypeGoogle:
+			if entry.CalendarID == "" {
+				return nil, fmt.Errorf("google calendar entry is missing calendar_id")
+			}
+			if googleSvc == nil {
+				svc, err := newGoogleSvc()
+				if err != nil {
+					return nil, err
+				}
+				googleSvc = svc
+				colors = &googleColors{calendarSvc: svc, retryConfig: retryConfig}
+			}
+			if entry.Privacy == privacyBusy {
+				source = &googleFreeBusySource{
+					calendarSvc: googleSvc,
+					calendarID:  entry.CalendarID,
+					retryConfig: retryConfig,
+				}
+				break
+			}
+			google := &googleSource{
+				calendarSvc: googleSvc,
+				calendarID:  entry.CalendarID,
+				retryConfig: retryConfig,
+				colors:      colors,
+				now:         now,
+			}
+			if entry.Privacy == "" || entry.Privacy == privacyFull {
+				google.snapshots = snapshots
+			} else {
+				// The sync state holds complete events, so it is kept in
+				// memory only for calendars showing less
+				google.deleteSavedState(snapshots)
+			}
+			source = google
+		case sourceTypeICS:
+			if entry.URL == "" && entry.Path == "" {
+				return nil, fmt.Errorf("ics calendar entry needs a url or a path")
+			}
+			source = &icsSource{
+				url:         entry.URL,
+				path:        entry.Path,
+				httpClient:  httpClient,
+				retryConfig: retryConfig,
+			}
+		case sourceTypeCalDAV:
+			if entry.URL == "" {
+				return nil, fmt.Errorf("caldav calendar entry is missing url")
+			}
+			var password string
+			if entry.PasswordFile != "" {
+				passwordBytes, err := os.ReadFile(entry.PasswordFile)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read caldav password: %v", err)
+				}
+				password = strings.TrimSpace(string(passwordBytes))
+			}
+			source = &caldavSource{
+				url:         entry.URL,
+				username:    entry.Username,
+				password:    password,
+				httpClient:  httpClient,
+				retryConfig: retryConfig,
+			}
+		case sourceTypeSchedule:
+			schedule, err := newScheduleSource(entry.Schedule)
+			if err != nil {
+				return nil, err
+			}
+			source = schedule
+		default:
+			return nil, fmt.Errorf("unknown calendar type %q", entry.Type)
+		}
+
+		if err := validateColorSource(entry.ColorSource); err != nil {
+			return nil, err
+		}
+		filter, err := newEventFilter(entry.Filters)
+		if err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, calendarSource{config: entry, source: source, filter: filter})
+	}
+
+	return sources, nil
+}
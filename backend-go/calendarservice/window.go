@@ -0,0 +1,86 @@
+package calendarservice
+
+import (
+	"fmt"
+	"time"
+)
+
+// Values accepted in CalendarConfig.WindowMode
+const (
+	windowModeWeeks = "weeks" // NumberOfWeeks full weeks starting this week
+	windowModeMonth = "month" // The full weeks covering the current month
+	windowModeDays  = "days"  // NumberOfDays days starting today
+)
+
+// Number of days shown in "days" mode when NumberOfDays is not set
+const defaultNumberOfDays = 7
+
+// validateWindowMode rejects unknown CalendarConfig.WindowMode values
+func validateWindowMode(mode string) error {
+	switch mode {
+	case "", windowModeWeeks, windowModeMonth, windowModeDays:
+		return nil
+	}
+	return fmt.Errorf("unknown window_mode %q", mode)
+}
+
+// window returns the time range shown on the display, from midnight starting
+// its first day to midnight after its last day, in the display's timezone
+func (s *Service) window() (time.Time, time.Time) {
+	// Get current time in the display's timezone
+	loc := s.timezone()
+	now := s.now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	switch s.calendarConfig.WindowMode {
+	case windowModeMonth:
+		firstOfMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, loc)
+		lastOfMonth := time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, loc)
+		return s.startOfWeek(firstOfMonth), addDays(s.startOfWeek(lastOfMonth), 7)
+	case windowModeDays:
+		days := s.calendarConfig.NumberOfDays
+		if days <= 0 {
+			days = defaultNumberOfDays
+		}
+		return today, addDays(today, days)
+	default:
+		weekStart := s.startOfWeek(today)
+		return weekStart, addDays(weekStart, s.calendarConfig.NumberOfWeeks*7)
+	}
+}
+
+// startOfWeek returns the first day of the week containing day, based on the
+// configuration
+func (s *Service) startOfWeek(day time.Time) time.Time {
+	daysToSubtract := int(day.Weekday())
+	if !s.calendarConfig.StartOnSunday {
+		// For Monday start: if day is Monday (1), we stay on Monday
+		if daysToSubtract == 0 { // Sunday
+			daysToSubtract = 6
+		} else {
+			daysToSubtract--
+		}
+	}
+	return addDays(day, -daysToSubtract)
+}
+
+// addDays moves a midnight by whole days, keeping it at midnight across DST
+// transitions
+func addDays(day time.Time, days int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day()+days, 0, 0, 0, 0, day.Location())
+}
+
+// numberOfDays returns how many days the window covers
+func (c *calendarSnapshot) numberOfDays() int {
+	days := 0
+	for day := c.start; day.Before(c.end); day = addDays(day, 1) {
+		days++
+	}
+	return days
+}
+
+// numberOfWeeks returns how many week rows the window takes on the display,
+// counting a partial last row in "days" mode
+func (c *calendarSnapshot) numberOfWeeks() int {
+	return (c.numberOfDays() + 6) / 7
+}
@@ -0,0 +1,108 @@
+package calendarservice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+func TestWindowModes(t *testing.T) {
+	testCases := []struct {
+		name          string
+		config        models.CalendarConfig
+		now           time.Time
+		expectedStart string
+		expectedEnd   string
+		expectedWeeks int
+	}{
+		{
+			name:          "Weeks from Wednesday",
+			config:        models.CalendarConfig{StartOnSunday: true, NumberOfWeeks: 3},
+			now:           time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC),
+			expectedStart: "2024-01-07",
+			expectedEnd:   "2024-01-28",
+			expectedWeeks: 3,
+		},
+		{
+			name:          "Weeks from Saturday",
+			config:        models.CalendarConfig{StartOnSunday: true, NumberOfWeeks: 3, WindowMode: "weeks"},
+			now:           time.Date(2024, 1, 13, 23, 0, 0, 0, time.UTC),
+			expectedStart: "2024-01-07",
+			expectedEnd:   "2024-01-28",
+			expectedWeeks: 3,
+		},
+		{
+			name:          "Month starting on Sunday",
+			config:        models.CalendarConfig{StartOnSunday: true, WindowMode: "month"},
+			now:           time.Date(2024, 2, 14, 12, 0, 0, 0, time.UTC),
+			expectedStart: "2024-01-28",
+			expectedEnd:   "2024-03-03",
+			expectedWeeks: 5,
+		},
+		{
+			name:          "Month starting on Monday spanning six weeks",
+			config:        models.CalendarConfig{WindowMode: "month"},
+			now:           time.Date(2024, 9, 30, 12, 0, 0, 0, time.UTC),
+			expectedStart: "2024-08-26",
+			expectedEnd:   "2024-10-07",
+			expectedWeeks: 6,
+		},
+		{
+			name:          "Days from today",
+			config:        models.CalendarConfig{WindowMode: "days", NumberOfDays: 10},
+			now:           time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC),
+			expectedStart: "2024-01-10",
+			expectedEnd:   "2024-01-20",
+			expectedWeeks: 2,
+		},
+		{
+			name:          "Days defaults to a week",
+			config:        models.CalendarConfig{WindowMode: "days"},
+			now:           time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC),
+			expectedStart: "2024-01-10",
+			expectedEnd:   "2024-01-17",
+			expectedWeeks: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &Service{
+				calendarConfig: tc.config,
+				location:       time.UTC,
+				now:            func() time.Time { return tc.now },
+			}
+
+			start, end := svc.window()
+			if got := start.Format(scheduleDateLayout); got != tc.expectedStart {
+				t.Errorf("Expected start %s, got %s", tc.expectedStart, got)
+			}
+			if got := end.Format(scheduleDateLayout); got != tc.expectedEnd {
+				t.Errorf("Expected end %s, got %s", tc.expectedEnd, got)
+			}
+			if start.Hour() != 0 || end.Hour() != 0 {
+				t.Errorf("Expected the window to start and end at midnight, got %v..%v", start, end)
+			}
+
+			snap := &calendarSnapshot{config: tc.config, start: start, end: end}
+			if weeks := snap.numberOfWeeks(); weeks != tc.expectedWeeks {
+				t.Errorf("Expected %d weeks, got %d", tc.expectedWeeks, weeks)
+			}
+			if days := len(snap.grid(tc.now).Events); days != snap.numberOfDays() {
+				t.Errorf("Expected a grid day for each of the %d days, got %d", snap.numberOfDays(), days)
+			}
+		})
+	}
+}
+
+func TestValidateWindowMode(t *testing.T) {
+	for _, mode := range []string{"", "weeks", "month", "days"} {
+		if err := validateWindowMode(mode); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", mode, err)
+		}
+	}
+	if err := validateWindowMode("fortnight"); err == nil {
+		t.Error("Expected error for unknown window mode")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -41,6 +42,7 @@ func NewServer() (*Server, error) {
 
 func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("GET /api/calendar", s.cors(s.calendarSvc.HandleGetCalendar))
+	s.mux.HandleFunc("GET /api/calendar/grid", s.cors(s.calendarSvc.HandleGetCalendarGrid))
 	s.mux.HandleFunc("GET /api/weather", s.cors(s.weatherSvc.HandleGetWeather))
 }
 
@@ -67,6 +69,12 @@ func main() {
 
 	server.setupRoutes()
 
+	// Refresh calendars and weather in the background; handlers serve the
+	// latest snapshot
+	ctx := context.Background()
+	server.calendarSvc.Start(ctx)
+	server.weatherSvc.Start(ctx)
+
 	log.Printf("Server starting on :8000")
 	log.Fatal(http.ListenAndServe(":8000", server.mux))
 }
@@ -91,6 +91,12 @@ func TestSetupRoutes(t *testing.T) {
 			method:   http.MethodGet,
 			wantCode: http.StatusOK,
 		},
+		{
+			name:     "Calendar grid API",
+			path:     "/api/calendar/grid",
+			method:   http.MethodGet,
+			wantCode: http.StatusOK,
+		},
 		{
 			name:     "Weather API",
 			path:     "/api/weather",
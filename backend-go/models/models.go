@@ -1,22 +1,257 @@
 package models
 
+import "time"
+
 type CalendarConfig struct {
-	Calendars []struct {
-		CalendarID string `json:"calendar_id"`
-		Color      string `json:"color"`
-	} `json:"calendars"`
-	NumberOfWeeks int  `json:"number_of_weeks"`
-	StartOnSunday bool `json:"start_on_sunday"`
+	Calendars            []CalendarEntry `json:"calendars"`
+	NumberOfWeeks        int             `json:"number_of_weeks"`
+	StartOnSunday        bool            `json:"start_on_sunday"`
+	MaxConcurrentFetches int             `json:"max_concurrent_fetches,omitempty"`
+	// How often the background poller refreshes the calendars
+	RefreshIntervalMinutes int `json:"refresh_interval_minutes,omitempty"`
+	// Range of days shown: "weeks" (the default) shows NumberOfWeeks full
+	// weeks starting this week, "month" the full weeks covering the current
+	// month and "days" NumberOfDays days starting today
+	WindowMode   string `json:"window_mode,omitempty"`
+	NumberOfDays int    `json:"number_of_days,omitempty"`
+	// What to do with an event on several calendars: "first" (the default)
+	// keeps the copy of the first calendar listed, "multi_color" keeps one
+	// copy with the colors of all of them and "keep_both" shows every copy
+	DuplicatePolicy string `json:"duplicate_policy,omitempty"`
+	// IANA name of the timezone deciding what "today" is and when all-day
+	// events start, e.g. "America/New_York". Defaults to the system timezone.
+	Timezone string `json:"timezone,omitempty"`
+	// Rules classifying events into categories shown with an icon. Built-in
+	// rules are used when unset; an empty list turns classification off.
+	Categories []CategoryRule `json:"categories,omitempty"`
+	// Family members events are tagged with
+	People []Person `json:"people,omitempty"`
+}
+
+// CalendarEntry configures a single calendar shown on the display. Type
+// selects where events come from: "google" (the default) uses CalendarID,
+// "ics" reads an iCalendar feed from URL or a local file at Path, "caldav"
+// queries the calendar collection at URL and "schedule" generates all-day
+// pickup events from Schedule. MaxEvents, when set, caps how many
+// events of the calendar are shown; the earliest ones are kept.
+type CalendarEntry struct {
+	CalendarID   string          `json:"calendar_id"`
+	Color        string          `json:"color"`
+	Type         string          `json:"type,omitempty"`
+	URL          string          `json:"url,omitempty"`
+	Path         string          `json:"path,omitempty"`
+	Username     string          `json:"username,omitempty"`
+	PasswordFile string          `json:"password_file,omitempty"`
+	Schedule     *PickupSchedule `json:"schedule,omitempty"`
+	MaxEvents    int             `json:"max_events,omitempty"`
+	Filters      []FilterRule    `json:"filters,omitempty"`
+	// Which color events are shown with: "calendar" (the default), "event"
+	// or "event_overrides_calendar"
+	ColorSource string `json:"color_source,omitempty"`
+	// How much of the events is shown: "full" (the default), "title" without
+	// description, location and attendees, or "busy" showing anonymous busy
+	// blocks. Busy-only Google calendars are read with the Freebusy API.
+	Privacy string `json:"privacy,omitempty"`
+}
+
+// FilterRule matches events on all of its set conditions. Events matching an
+// "exclude" rule (the default Action) are hidden; when a calendar has
+// "include" rules, only events matching one of them are shown. Summary and
+// Description are regular expressions; the other conditions list accepted
+// values, e.g. ResponseStatus ["declined"] or EventType ["workingLocation"].
+type FilterRule struct {
+	Action         string   `json:"action,omitempty"`
+	Summary        string   `json:"summary,omitempty"`
+	Description    string   `json:"description,omitempty"`
+	ResponseStatus []string `json:"response_status,omitempty"`
+	Transparency   []string `json:"transparency,omitempty"`
+	EventType      []string `json:"event_type,omitempty"`
+	Visibility     []string `json:"visibility,omitempty"`
+}
+
+// CategoryRule puts events into Category, shown with Icon. An event matches
+// when it is on one of Calendars (calendar_id, url or path), if set, on a
+// calendar configured with one of Colors, if set, and its summary contains
+// one of Keywords as a whole word or matches the Summary regular expression,
+// if either is set. AllDay restricts the rule to all-day events. Badge events
+// are shown as an icon next to the day number instead of in the event list;
+// without an icon they are hidden. The first matching rule wins.
+type CategoryRule struct {
+	Category  string   `json:"category"`
+	Icon      string   `json:"icon"`
+	Keywords  []string `json:"keywords,omitempty"`
+	Summary   string   `json:"summary,omitempty"`
+	Calendars []string `json:"calendars,omitempty"`
+	Colors    []string `json:"colors,omitempty"`
+	AllDay    bool     `json:"all_day,omitempty"`
+	Badge     bool     `json:"badge,omitempty"`
+}
+
+// Person is a family member. Events are tagged with a person when one of
+// Emails is an attendee or the organizer, when they are on one of Calendars
+// (calendar_id, url or path), or when the summary starts with Name or one of
+// Aliases followed by a colon or dash, or in brackets, e.g. "Emma: Soccer".
+type Person struct {
+	Name      string   `json:"name"`
+	Initials  string   `json:"initials,omitempty"`
+	Color     string   `json:"color,omitempty"`
+	Emails    []string `json:"emails,omitempty"`
+	Aliases   []string `json:"aliases,omitempty"`
+	Calendars []string `json:"calendars,omitempty"`
+}
+
+// PersonTag is a family member an event belongs to
+type PersonTag struct {
+	Name     string `json:"name"`
+	Initials string `json:"initials,omitempty"`
+	Color    string `json:"color,omitempty"`
+}
+
+// PickupSchedule describes recurring trash and recycling pickups. Dates are
+// YYYY-MM-DD. A pickup falling on a holiday moves to the next day that is not
+// a holiday; with HolidayShift "rest_of_week" every later pickup that week
+// moves too. A pickup moved onto a skip date, or onto a day with the same
+// pickup, is dropped.
+type PickupSchedule struct {
+	Pickups      []PickupRule `json:"pickups"`
+	Holidays     []string     `json:"holidays,omitempty"`
+	HolidayShift string       `json:"holiday_shift,omitempty"`
+	SkipDates    []string     `json:"skip_dates,omitempty"`
+}
+
+// PickupRule is a pickup on the given weekdays, every EveryWeeks weeks
+// counted from the week containing Anchor
+type PickupRule struct {
+	Summary    string   `json:"summary"`
+	Weekdays   []string `json:"weekdays"`
+	EveryWeeks int      `json:"every_weeks,omitempty"`
+	Anchor     string   `json:"anchor,omitempty"`
+}
+
+// CalendarStatus reports the outcome of the last fetch of a single calendar.
+// Stale is set when the events come from the last-known-good snapshot, which
+// is AgeSeconds old. Truncated is set when MaxEvents hid HiddenEvents events.
+type CalendarStatus struct {
+	Calendar     string     `json:"calendar"`
+	Color        string     `json:"color"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	LastSuccess  *time.Time `json:"lastSuccess,omitempty"`
+	Stale        bool       `json:"stale,omitempty"`
+	AgeSeconds   int64      `json:"ageSeconds,omitempty"`
+	Truncated    bool       `json:"truncated,omitempty"`
+	HiddenEvents int        `json:"hiddenEvents,omitempty"`
+}
+
+// CalendarAPIVersion is the version of the /api/calendar JSON contract. It is
+// bumped whenever a field is removed or changes meaning.
+const CalendarAPIVersion = 1
+
+// CalendarEvent is an event as served by /api/calendar, whichever source it
+// comes from. It matches CalendarEvent in frontend/src/types/calendar.ts.
+type CalendarEvent struct {
+	ID          string    `json:"id"`
+	Summary     string    `json:"summary"`
+	Description string    `json:"description,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	Start       EventTime `json:"start"`
+	End         EventTime `json:"end"`
+	// ColorID is the color the event is shown with, picked from CalendarColor
+	// and EventColor according to the calendar's color source
+	ColorID       string `json:"colorId,omitempty"`
+	CalendarColor string `json:"calendarColor,omitempty"` // Configured color of the calendar
+	EventColor    string `json:"eventColor,omitempty"`    // Color set on the event itself
+	// Colors of every calendar the event is on, when duplicates are merged
+	Colors []string `json:"colors,omitempty"`
+	// Every calendar the event is on, in configuration order. Copies of the
+	// event on several calendars all list each other's calendars.
+	Calendars []EventCalendar `json:"calendars,omitempty"`
+	// Classification by the category rules
+	Category string `json:"category,omitempty"`
+	Icon     string `json:"icon,omitempty"`
+	Badge    bool   `json:"badge,omitempty"`
+	// Family members the event belongs to
+	People []PersonTag `json:"people,omitempty"`
+
+	// iCalendar UID, shared by copies of the event on different calendars
+	ICalUID string `json:"-"`
+
+	// Attributes for filter rules, not part of the JSON contract
+	Transparency   string `json:"-"` // "opaque" or "transparent"
+	Visibility     string `json:"-"` // "default", "public", "private" or "confidential"
+	EventType      string `json:"-"` // Google event type, "default" for other sources
+	ResponseStatus string `json:"-"` // Response of the calendar owner, if invited
+
+	// Email addresses for tagging people, not part of the JSON contract
+	Organizer string   `json:"-"`
+	Attendees []string `json:"-"`
+}
+
+// EventCalendar is a calendar an event is on
+type EventCalendar struct {
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+// EventTime is the start or end of an event: Date (YYYY-MM-DD) for all-day
+// events, DateTime (RFC 3339) otherwise
+type EventTime struct {
+	Date     string `json:"date,omitempty"`
+	DateTime string `json:"dateTime,omitempty"`
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// CalendarData is the day grid served by /api/calendar/grid. It matches
+// CalendarData in frontend/src/types/calendar.ts.
+type CalendarData struct {
+	Version        int              `json:"version"`
+	StartDate      string           `json:"startDate"`
+	EndDate        string           `json:"endDate"`
+	StartOnSunday  bool             `json:"startOnSunday"`
+	NumberOfWeeks  int              `json:"numberOfWeeks"`
+	Timezone       string           `json:"timezone"`
+	Events         []DayData        `json:"events"` // NumberOfWeeks*7 days
+	CalendarStatus []CalendarStatus `json:"calendarStatus"`
+	Stale          bool             `json:"stale"`
+	Truncated      bool             `json:"truncated"`
+	FetchedAt      string           `json:"fetchedAt"`
+}
+
+// DayData is a single day of the calendar grid. Lanes places the events that
+// span several days, so they can be drawn as continuous bars.
+type DayData struct {
+	Date           string          `json:"date"`
+	Events         []CalendarEvent `json:"events"`
+	IsToday        bool            `json:"isToday"`
+	IsCurrentMonth bool            `json:"isCurrentMonth"`
+	Lanes          []EventLane     `json:"lanes"`
+}
+
+// EventLane is the horizontal lane a multi-day event occupies in a day. An
+// event keeps its lane on every day it covers, across week rows.
+type EventLane struct {
+	EventID       string `json:"eventId"`
+	Lane          int    `json:"lane"`
+	ContinuesFrom bool   `json:"continuesFrom"` // The event started on an earlier day
+	ContinuesInto bool   `json:"continuesInto"` // The event goes on the next day
 }
 
 type WeatherConfig struct {
-	Weather struct {
-		APIKey   string  `json:"api_key"`
-		Lat      float64 `json:"lat"`
-		Lon      float64 `json:"lon"`
-		Location string  `json:"location"`
-		Units    string  `json:"units"`
-	} `json:"weather"`
+	Weather WeatherSettings `json:"weather"`
+}
+
+type WeatherSettings struct {
+	APIKey                 string  `json:"api_key"`
+	Lat                    float64 `json:"lat"`
+	Lon                    float64 `json:"lon"`
+	Location               string  `json:"location"`
+	Units                  string  `json:"units"`
+	RefreshIntervalMinutes int     `json:"refresh_interval_minutes,omitempty"`
+	// Weather service: "openweathermap" (the default, needs APIKey),
+	// "open-meteo" or "nws" (US National Weather Service, US locations only)
+	Provider string `json:"provider,omitempty"`
+	// Sent to the NWS API, which asks for contact details in the User-Agent
+	UserAgent string `json:"user_agent,omitempty"`
 }
 
 type WeatherData struct {
@@ -35,6 +270,65 @@ type WeatherData struct {
 	Location       string          `json:"location"`
 	HourlyForecast []ForecastData  `json:"hourly_forecast"`
 	DailyForecast  []ForecastData  `json:"daily_forecast"`
+
+	// Chance of precipitation today (0 to 1), and the rain and snow of the
+	// last hour in mm
+	Pop  float64 `json:"pop"`
+	Rain float64 `json:"rain,omitempty"`
+	Snow float64 `json:"snow,omitempty"`
+
+	// Today's sun and moon, when the provider has them
+	Astronomy *Astronomy `json:"astronomy,omitempty"`
+
+	// Weather alerts that haven't expired, most severe first
+	Alerts []WeatherAlert `json:"alerts,omitempty"`
+
+	// Precipitation in the next hour, when the provider has a minutely
+	// forecast
+	Nowcast *Nowcast `json:"nowcast,omitempty"`
+
+	// Problems with the provider's response that were worked around, e.g.
+	// "OpenWeatherMap response daily is empty"
+	Warnings []string `json:"warnings,omitempty"`
+
+	// When the data was fetched from the weather provider (unix seconds)
+	FetchedAt int64 `json:"fetched_at,omitempty"`
+
+	// Set when the data is a last-known-good snapshot served because
+	// the weather provider could not be reached
+	Stale           bool  `json:"stale,omitempty"`
+	StaleAgeSeconds int64 `json:"stale_age_seconds,omitempty"`
+}
+
+// WeatherAlert is a weather warning, watch or advisory issued for the
+// location. Start and End are unix seconds; End is 0 when the alert has no
+// announced end.
+type WeatherAlert struct {
+	Sender      string `json:"sender"`
+	Event       string `json:"event"`
+	Start       int64  `json:"start"`
+	End         int64  `json:"end"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"` // "extreme", "severe", "moderate", "minor" or "unknown"
+	Active      bool   `json:"active"`   // Whether the alert has started
+}
+
+// Nowcast summarizes the precipitation expected in the next hour. StartsAt and
+// StopsAt are unix seconds, 0 when precipitation doesn't start or stop within
+// the hour.
+type Nowcast struct {
+	Summary       string          `json:"summary"` // e.g. "Light rain starting in 15 minutes"
+	Precipitating bool            `json:"precipitating"`
+	StartsAt      int64           `json:"starts_at,omitempty"`
+	StopsAt       int64           `json:"stops_at,omitempty"`
+	PeakIntensity float64         `json:"peak_intensity"` // mm/h
+	Minutes       []NowcastMinute `json:"minutes"`
+}
+
+// NowcastMinute is the precipitation intensity forecast for one minute
+type NowcastMinute struct {
+	Timestamp     int64   `json:"timestamp"`
+	Precipitation float64 `json:"precipitation"` // mm/h
 }
 
 type ForecastData struct {
@@ -44,13 +338,35 @@ type ForecastData struct {
 	Icon        int             `json:"icon"`
 	Humidity    int             `json:"humidity"`
 	WindSpeed   float64         `json:"wind_speed"`
+	Pop         float64         `json:"pop"`            // Chance of precipitation, 0 to 1
+	Rain        float64         `json:"rain,omitempty"` // mm in the hour or day
+	Snow        float64         `json:"snow,omitempty"` // mm in the hour or day
+	UVI         float64         `json:"uvi"`
+	Summary     string          `json:"summary,omitempty"` // Text summary of the day
+
+	// Sun and moon of daily entries
+	Astronomy *Astronomy `json:"astronomy,omitempty"`
+}
+
+// Astronomy holds the sun and moon of a day. Times are unix seconds, 0 when
+// the sun or moon doesn't rise or set that day.
+type Astronomy struct {
+	Sunrise         int64   `json:"sunrise"`
+	Sunset          int64   `json:"sunset"`
+	DaylightSeconds int64   `json:"daylight_seconds"`
+	Moonrise        int64   `json:"moonrise"`
+	Moonset         int64   `json:"moonset"`
+	MoonPhase       float64 `json:"moon_phase"`      // 0 and 1 are new moon, 0.5 full moon
+	MoonPhaseName   string  `json:"moon_phase_name"` // e.g. "Waxing Gibbous"
 }
 
+// TemperatureData holds a temperature; TempMin and TempMax are nil when the
+// range of the day is unknown, as for hourly forecasts
 type TemperatureData struct {
-	Temp      float64 `json:"temp"`
-	TempMin   float64 `json:"min"`
-	TempMax   float64 `json:"max"`
-	FeelsLike float64 `json:"feels_like"`
+	Temp      float64  `json:"temp"`
+	TempMin   *float64 `json:"min,omitempty"`
+	TempMax   *float64 `json:"max,omitempty"`
+	FeelsLike float64  `json:"feels_like"`
 }
 
 type OWMWeatherData struct {
@@ -73,9 +389,18 @@ type OWMWeatherData struct {
 		WindDeg    float64        `json:"wind_deg"`
 		WindGust   float64        `json:"wind_gust"`
 		Weather    []OWMCondition `json:"weather"`
+		Rain       OWMVolume      `json:"rain"`
+		Snow       OWMVolume      `json:"snow"`
 	} `json:"current"`
-	Hourly []OWMHourlyForecast `json:"hourly"`
-	Daily  []OWMDailyForecast  `json:"daily"`
+	Hourly   []OWMHourlyForecast `json:"hourly"`
+	Daily    []OWMDailyForecast  `json:"daily"`
+	Alerts   []OWMAlert          `json:"alerts"`
+	Minutely []OWMMinutely       `json:"minutely"`
+}
+
+type OWMMinutely struct {
+	Timestamp     int64   `json:"dt"`
+	Precipitation float64 `json:"precipitation"`
 }
 
 type OWMHourlyForecast struct {
@@ -93,6 +418,13 @@ type OWMHourlyForecast struct {
 	WindGust   float64        `json:"wind_gust"`
 	Weather    []OWMCondition `json:"weather"`
 	Pop        float64        `json:"pop"`
+	Rain       OWMVolume      `json:"rain"`
+	Snow       OWMVolume      `json:"snow"`
+}
+
+// OWMVolume is the precipitation of the last or next hour in mm
+type OWMVolume struct {
+	OneHour float64 `json:"1h"`
 }
 
 type OWMDailyForecast struct {
@@ -126,9 +458,20 @@ type OWMDailyForecast struct {
 	Weather   []OWMCondition `json:"weather"`
 	Clouds    float64        `json:"clouds"`
 	Pop       float64        `json:"pop"`
+	Rain      float64        `json:"rain"` // mm
+	Snow      float64        `json:"snow"` // mm
 	UVI       float64        `json:"uvi"`
 }
 
+type OWMAlert struct {
+	SenderName  string   `json:"sender_name"`
+	Event       string   `json:"event"`
+	Start       int64    `json:"start"`
+	End         int64    `json:"end"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+}
+
 type OWMCondition struct {
 	ID          int    `json:"id"`
 	Main        string `json:"main"`
@@ -0,0 +1,505 @@
+package recurrence
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ part of a recurrence rule
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+// maxPeriods bounds the number of periods evaluated for a single rule so a
+// malformed rule can't spin forever
+const maxPeriods = 100000
+
+// WeekdayNum is a BYDAY entry such as MO, 2SU or -1FR. N is 0 when no
+// ordinal is given.
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// Rule is a parsed RRULE (RFC 5545 section 3.3.10)
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	WeekStart  time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// ParseRule parses the value of an RRULE property, with or without the
+// "RRULE:" prefix. Date-only UNTIL values are interpreted in loc.
+func ParseRule(s string, loc *time.Location) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+	hasFreq := false
+
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed rule part %q", part)
+		}
+		key = strings.ToUpper(key)
+		value = strings.ToUpper(value)
+
+		var err error
+		switch key {
+		case "FREQ":
+			hasFreq = true
+			switch value {
+			case "DAILY":
+				rule.Freq = Daily
+			case "WEEKLY":
+				rule.Freq = Weekly
+			case "MONTHLY":
+				rule.Freq = Monthly
+			case "YEARLY":
+				rule.Freq = Yearly
+			default:
+				return nil, fmt.Errorf("unsupported frequency %q", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(value)
+			if err == nil && rule.Interval < 1 {
+				err = fmt.Errorf("interval must be positive")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(value)
+		case "UNTIL":
+			rule.Until, err = parseUntil(value, loc)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseIntList(value, -31, 31)
+		case "BYMONTH":
+			rule.ByMonth, err = parseIntList(value, 1, 12)
+		case "BYSETPOS":
+			rule.BySetPos, err = parseIntList(value, -366, 366)
+		case "WKST":
+			wd, ok := weekdays[value]
+			if !ok {
+				err = fmt.Errorf("unknown weekday %q", value)
+			}
+			rule.WeekStart = wd
+		default:
+			return nil, fmt.Errorf("unsupported rule part %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", key, err)
+		}
+	}
+
+	if !hasFreq {
+		return nil, fmt.Errorf("rule is missing FREQ")
+	}
+
+	return rule, nil
+}
+
+func parseUntil(value string, loc *time.Location) (time.Time, error) {
+	switch {
+	case len(value) == len("20060102"):
+		// A date-only UNTIL includes the whole day
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t.AddDate(0, 0, 1).Add(-time.Second), err
+	case strings.HasSuffix(value, "Z"):
+		return time.Parse("20060102T150405Z", value)
+	default:
+		return time.ParseInLocation("20060102T150405", value, loc)
+	}
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("malformed weekday %q", item)
+		}
+		wd, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", item)
+		}
+		n := 0
+		if prefix := item[:len(item)-2]; prefix != "" {
+			var err error
+			if n, err = strconv.Atoi(prefix); err != nil || n == 0 {
+				return nil, fmt.Errorf("malformed weekday %q", item)
+			}
+		}
+		days = append(days, WeekdayNum{Weekday: wd, N: n})
+	}
+	return days, nil
+}
+
+func parseIntList(value string, min, max int) ([]int, error) {
+	var list []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("value %q out of range", item)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// Set is a recurrence set: DTSTART plus its RRULEs and RDATEs, minus EXDATEs.
+// Occurrences are computed on the wall clock of Start's location, so a
+// 09:00 meeting stays at 09:00 across DST transitions.
+type Set struct {
+	Start   time.Time
+	Rules   []*Rule
+	RDates  []time.Time
+	ExDates []time.Time
+}
+
+// Between returns the occurrences starting in [from, to), in order
+func (s *Set) Between(from, to time.Time) []time.Time {
+	excluded := map[int64]bool{}
+	for _, ex := range s.ExDates {
+		excluded[ex.UnixNano()] = true
+	}
+
+	seen := map[int64]bool{}
+	var occurrences []time.Time
+	add := func(t time.Time) {
+		key := t.UnixNano()
+		if seen[key] || excluded[key] || t.Before(from) || !t.Before(to) {
+			return
+		}
+		seen[key] = true
+		occurrences = append(occurrences, t)
+	}
+
+	add(s.Start)
+	for _, rdate := range s.RDates {
+		add(rdate)
+	}
+	for _, rule := range s.Rules {
+		rule.expand(s.Start, to, add)
+	}
+
+	sort.Slice(occurrences, func(i, j int) bool {
+		return occurrences[i].Before(occurrences[j])
+	})
+	return occurrences
+}
+
+// expand calls emit for every occurrence of the rule from start until to
+func (r *Rule) expand(start, to time.Time, emit func(time.Time)) {
+	loc := start.Location()
+
+	// DTSTART is the first instance and counts toward COUNT even when the
+	// rule doesn't generate it (RFC 5545 section 3.8.5.3)
+	count := 0
+	if !r.generates(start) {
+		count = 1
+	}
+
+	for period := 0; period < maxPeriods; period++ {
+		dates, periodStart := r.candidates(start, period)
+		if !periodStart.Before(to) {
+			return
+		}
+
+		for _, d := range dates {
+			t := time.Date(d.Year(), d.Month(), d.Day(), start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), loc)
+			if t.Before(start) {
+				continue
+			}
+			if !r.Until.IsZero() && t.After(r.Until) {
+				return
+			}
+			if !t.Before(to) {
+				return
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return
+			}
+			emit(t)
+		}
+	}
+}
+
+// generates reports whether the rule produces start's own date
+func (r *Rule) generates(start time.Time) bool {
+	dates, _ := r.candidates(start, 0)
+	y, m, d := start.Date()
+	for _, date := range dates {
+		if date.Year() == y && date.Month() == m && date.Day() == d {
+			return true
+		}
+	}
+	return false
+}
+
+// candidates returns the sorted dates (at midnight UTC) generated by the rule
+// in the n-th period after start, and the first day of that period
+func (r *Rule) candidates(start time.Time, n int) ([]time.Time, time.Time) {
+	y, m, d := start.Date()
+	var periodStart time.Time
+	var dates []time.Time
+
+	switch r.Freq {
+	case Daily:
+		day := time.Date(y, m, d+n*r.Interval, 0, 0, 0, 0, time.UTC)
+		periodStart = day
+		if r.matchesMonth(day) && r.matchesMonthDay(day) && r.matchesWeekday(day) {
+			dates = []time.Time{day}
+		}
+
+	case Weekly:
+		first := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		first = first.AddDate(0, 0, -((int(first.Weekday()) - int(r.WeekStart) + 7) % 7))
+		first = first.AddDate(0, 0, 7*n*r.Interval)
+		periodStart = first
+
+		days := []time.Weekday{start.Weekday()}
+		if len(r.ByDay) > 0 {
+			days = days[:0]
+			for _, wd := range r.ByDay {
+				days = append(days, wd.Weekday)
+			}
+		}
+		for _, wd := range days {
+			day := first.AddDate(0, 0, (int(wd)-int(r.WeekStart)+7)%7)
+			if r.matchesMonth(day) {
+				dates = append(dates, day)
+			}
+		}
+
+	case Monthly:
+		first := time.Date(y, m+time.Month(n*r.Interval), 1, 0, 0, 0, 0, time.UTC)
+		periodStart = first
+		if r.matchesMonth(first) {
+			dates = r.monthDays(first.Year(), first.Month(), d)
+		}
+
+	case Yearly:
+		year := y + n*r.Interval
+		periodStart = time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		switch {
+		case len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0 && len(r.ByDay) > 0:
+			// BYDAY ordinals count within the whole year
+			dates = yearWeekdays(year, r.ByDay)
+		case len(r.ByMonth) == 0 && len(r.ByMonthDay) > 0:
+			// BYMONTHDAY alone applies to every month of the year
+			for month := time.January; month <= time.December; month++ {
+				dates = append(dates, r.monthDays(year, month, d)...)
+			}
+		case len(r.ByMonth) == 0:
+			dates = r.monthDays(year, m, d)
+		default:
+			for _, month := range r.ByMonth {
+				dates = append(dates, r.monthDays(year, time.Month(month), d)...)
+			}
+		}
+	}
+
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	dates = dedupe(dates)
+	if len(r.BySetPos) > 0 {
+		dates = applySetPos(dates, r.BySetPos)
+	}
+
+	return dates, time.Date(periodStart.Year(), periodStart.Month(), periodStart.Day(), 0, 0, 0, 0, start.Location())
+}
+
+// monthDays returns the days of the month selected by BYMONTHDAY and BYDAY,
+// falling back to the day of DTSTART. Months lacking that day are skipped.
+func (r *Rule) monthDays(year int, month time.Month, startDay int) []time.Time {
+	daysInMonth := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	var days []int
+
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, md := range r.ByMonthDay {
+			if md < 0 {
+				md = daysInMonth + md + 1
+			}
+			if md >= 1 && md <= daysInMonth {
+				days = append(days, md)
+			}
+		}
+		if len(r.ByDay) > 0 {
+			// BYMONTHDAY and BYDAY together select the intersection
+			var filtered []int
+			for _, day := range days {
+				if r.matchesWeekday(time.Date(year, month, day, 0, 0, 0, 0, time.UTC)) {
+					filtered = append(filtered, day)
+				}
+			}
+			days = filtered
+		}
+	case len(r.ByDay) > 0:
+		for _, wd := range r.ByDay {
+			if wd.N != 0 {
+				if day := NthWeekdayOfMonth(year, month, wd.Weekday, wd.N); day != 0 {
+					days = append(days, day)
+				}
+				continue
+			}
+			for day := 1; day <= daysInMonth; day++ {
+				if time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Weekday() == wd.Weekday {
+					days = append(days, day)
+				}
+			}
+		}
+	case startDay <= daysInMonth:
+		days = []int{startDay}
+	}
+
+	dates := make([]time.Time, 0, len(days))
+	for _, day := range days {
+		dates = append(dates, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+	}
+	return dates
+}
+
+// yearWeekdays returns the days of the year matching BYDAY, with ordinals
+// counted within the year
+func yearWeekdays(year int, byDay []WeekdayNum) []time.Time {
+	first := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	var dates []time.Time
+	for _, wd := range byDay {
+		switch {
+		case wd.N > 0:
+			day := first.AddDate(0, 0, (int(wd.Weekday)-int(first.Weekday())+7)%7+(wd.N-1)*7)
+			if day.Year() == year {
+				dates = append(dates, day)
+			}
+		case wd.N < 0:
+			day := last.AddDate(0, 0, -((int(last.Weekday())-int(wd.Weekday)+7)%7)+(wd.N+1)*7)
+			if day.Year() == year {
+				dates = append(dates, day)
+			}
+		default:
+			for day := first; day.Year() == year; day = day.AddDate(0, 0, 1) {
+				if day.Weekday() == wd.Weekday {
+					dates = append(dates, day)
+				}
+			}
+		}
+	}
+	return dates
+}
+
+func (r *Rule) matchesMonth(day time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if time.Month(m) == day.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, md := range r.ByMonthDay {
+		if md < 0 {
+			md = daysInMonth + md + 1
+		}
+		if md == day.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesWeekday(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Weekday == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func dedupe(dates []time.Time) []time.Time {
+	out := dates[:0]
+	for i, d := range dates {
+		if i == 0 || !d.Equal(dates[i-1]) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func applySetPos(dates []time.Time, setPos []int) []time.Time {
+	var selected []time.Time
+	for _, pos := range setPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(dates) + pos
+		}
+		if i >= 0 && i < len(dates) {
+			selected = append(selected, dates[i])
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Before(selected[j]) })
+	return dedupe(selected)
+}
+
+// NthWeekdayOfMonth returns the day of the month of the n-th weekday, counting
+// from the end of the month for negative n, or 0 if it does not exist
+func NthWeekdayOfMonth(year int, month time.Month, weekday time.Weekday, n int) int {
+	daysInMonth := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	if n > 0 {
+		first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Weekday()
+		day := 1 + (int(weekday)-int(first)+7)%7 + (n-1)*7
+		if day > daysInMonth {
+			return 0
+		}
+		return day
+	}
+
+	last := time.Date(year, month, daysInMonth, 0, 0, 0, 0, time.UTC).Weekday()
+	day := daysInMonth - (int(last)-int(weekday)+7)%7 + (n+1)*7
+	if day < 1 {
+		return 0
+	}
+	return day
+}
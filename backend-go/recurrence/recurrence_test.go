@@ -0,0 +1,214 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("timezone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+func mustParseRule(t *testing.T, s string, loc *time.Location) *Rule {
+	rule, err := ParseRule(s, loc)
+	if err != nil {
+		t.Fatalf("Failed to parse rule %q: %v", s, err)
+	}
+	return rule
+}
+
+func formatAll(times []time.Time) []string {
+	formatted := make([]string, len(times))
+	for i, t := range times {
+		formatted[i] = t.Format(time.RFC3339)
+	}
+	return formatted
+}
+
+func TestBetween(t *testing.T) {
+	ny := mustLoadLocation(t, "America/New_York")
+
+	testCases := []struct {
+		name     string
+		start    time.Time
+		rule     string
+		from     time.Time
+		to       time.Time
+		expected []string
+	}{
+		{
+			name:  "Weekly across spring DST keeps wall clock",
+			start: time.Date(2024, 3, 1, 9, 0, 0, 0, ny),
+			rule:  "FREQ=WEEKLY",
+			from:  time.Date(2024, 3, 1, 0, 0, 0, 0, ny),
+			to:    time.Date(2024, 3, 16, 0, 0, 0, 0, ny),
+			expected: []string{
+				"2024-03-01T09:00:00-05:00",
+				"2024-03-08T09:00:00-05:00",
+				"2024-03-15T09:00:00-04:00",
+			},
+		},
+		{
+			name:  "Weekly on several days with interval",
+			start: time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC),
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE",
+			from:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC),
+			expected: []string{
+				"2024-01-01T18:00:00Z",
+				"2024-01-03T18:00:00Z",
+				"2024-01-15T18:00:00Z",
+				"2024-01-17T18:00:00Z",
+			},
+		},
+		{
+			name:  "Start off the rule counts toward COUNT",
+			start: time.Date(2024, 1, 3, 9, 0, 0, 0, time.UTC),
+			rule:  "FREQ=WEEKLY;BYDAY=MO;COUNT=2",
+			from:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{
+				"2024-01-03T09:00:00Z",
+				"2024-01-08T09:00:00Z",
+			},
+		},
+		{
+			name:  "Monthly on the last Friday",
+			start: time.Date(2024, 1, 26, 12, 0, 0, 0, time.UTC),
+			rule:  "FREQ=MONTHLY;BYDAY=-1FR",
+			from:  time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{
+				"2024-02-23T12:00:00Z",
+				"2024-03-29T12:00:00Z",
+			},
+		},
+		{
+			name:  "Monthly skips months without the day",
+			start: time.Date(2024, 1, 31, 8, 0, 0, 0, time.UTC),
+			rule:  "FREQ=MONTHLY",
+			from:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{
+				"2024-01-31T08:00:00Z",
+				"2024-03-31T08:00:00Z",
+			},
+		},
+		{
+			name:  "Last weekday of the month with BYSETPOS",
+			start: time.Date(2024, 1, 31, 8, 0, 0, 0, time.UTC),
+			rule:  "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			from:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{
+				"2024-03-29T08:00:00Z",
+			},
+		},
+		{
+			name:  "Yearly birthday",
+			start: time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC),
+			rule:  "FREQ=YEARLY",
+			from:  time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{
+				"2024-02-29T00:00:00Z",
+			},
+		},
+		{
+			name:  "Yearly Thanksgiving",
+			start: time.Date(2020, 11, 26, 0, 0, 0, 0, time.UTC),
+			rule:  "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH",
+			from:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{
+				"2024-11-28T00:00:00Z",
+				"2025-11-27T00:00:00Z",
+			},
+		},
+		{
+			name:  "Daily with count",
+			start: time.Date(2024, 1, 1, 7, 0, 0, 0, time.UTC),
+			rule:  "FREQ=DAILY;COUNT=3",
+			from:  time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{
+				"2024-01-02T07:00:00Z",
+				"2024-01-03T07:00:00Z",
+			},
+		},
+		{
+			name:  "Daily until",
+			start: time.Date(2024, 1, 1, 7, 0, 0, 0, time.UTC),
+			rule:  "FREQ=DAILY;UNTIL=20240102T070000Z",
+			from:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			expected: []string{
+				"2024-01-01T07:00:00Z",
+				"2024-01-02T07:00:00Z",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			set := &Set{
+				Start: tc.start,
+				Rules: []*Rule{mustParseRule(t, tc.rule, tc.start.Location())},
+			}
+
+			got := formatAll(set.Between(tc.from, tc.to))
+			if len(got) != len(tc.expected) {
+				t.Fatalf("Expected %v, got %v", tc.expected, got)
+			}
+			for i := range got {
+				if got[i] != tc.expected[i] {
+					t.Errorf("Occurrence %d: expected %s, got %s", i, tc.expected[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBetweenRDatesAndExDates(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	set := &Set{
+		Start:   start,
+		Rules:   []*Rule{mustParseRule(t, "FREQ=DAILY;COUNT=4", time.UTC)},
+		RDates:  []time.Time{time.Date(2024, 1, 10, 15, 0, 0, 0, time.UTC)},
+		ExDates: []time.Time{time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)},
+	}
+
+	got := formatAll(set.Between(start, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)))
+	expected := []string{
+		"2024-01-01T09:00:00Z",
+		"2024-01-03T09:00:00Z",
+		"2024-01-04T09:00:00Z",
+		"2024-01-10T15:00:00Z",
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Errorf("Occurrence %d: expected %s, got %s", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for _, rule := range []string{
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+	} {
+		if _, err := ParseRule(rule, time.UTC); err == nil {
+			t.Errorf("Expected error for rule %q", rule)
+		}
+	}
+}
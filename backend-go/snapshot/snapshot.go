@@ -0,0 +1,104 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Store persists the last successful upstream responses as JSON files so they
+// can be served when a later fetch fails, including after a restart
+type Store struct {
+	dir string
+}
+
+// envelope is the on-disk format of a snapshot
+type envelope struct {
+	FetchedAt time.Time       `json:"fetchedAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// NewStore returns a Store writing to dir, creating it if needed
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %v", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// NewStoreFromEnv returns a Store in the directory named by SNAPSHOT_DIR
+func NewStoreFromEnv() (*Store, error) {
+	dir := os.Getenv("SNAPSHOT_DIR")
+	if dir == "" {
+		dir = "../snapshots"
+		fmt.Println("SNAPSHOT_DIR is not set in the .env file, using default: ", dir)
+	}
+	return NewStore(dir)
+}
+
+// Save stores value under name. The file is replaced atomically so a crash
+// mid-write never leaves a truncated snapshot behind.
+func (s *Store) Save(name string, value any, fetchedAt time.Time) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot %s: %v", name, err)
+	}
+
+	contents, err := json.Marshal(envelope{FetchedAt: fetchedAt, Data: data})
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot %s: %v", name, err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot %s: %v", name, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot %s: %v", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot %s: %v", name, err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path(name)); err != nil {
+		return fmt.Errorf("failed to save snapshot %s: %v", name, err)
+	}
+	return nil
+}
+
+// Load decodes the snapshot stored under name into value and returns when it
+// was fetched. The error wraps os.ErrNotExist when there is no snapshot yet.
+func (s *Store) Load(name string, value any) (time.Time, error) {
+	contents, err := os.ReadFile(s.path(name))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read snapshot %s: %w", name, err)
+	}
+
+	var env envelope
+	if err := json.Unmarshal(contents, &env); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse snapshot %s: %v", name, err)
+	}
+	if err := json.Unmarshal(env.Data, value); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse snapshot %s: %v", name, err)
+	}
+
+	return env.FetchedAt, nil
+}
+
+// Delete removes the snapshot stored under name, if any
+func (s *Store) Delete(name string) error {
+	if err := os.Remove(s.path(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete snapshot %s: %v", name, err)
+	}
+	return nil
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
@@ -0,0 +1,78 @@
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	fetchedAt := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	if err := store.Save("weather", map[string]float64{"temp": 20.5}, fetchedAt); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	// A new store on the same directory sees the snapshot, as after a restart
+	reopened, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+
+	var value map[string]float64
+	got, err := reopened.Load("weather", &value)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	if !got.Equal(fetchedAt) {
+		t.Errorf("Expected fetchedAt %v, got %v", fetchedAt, got)
+	}
+	if value["temp"] != 20.5 {
+		t.Errorf("Expected temp 20.5, got %v", value["temp"])
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the snapshot file to remain, got %d entries", len(entries))
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	var value map[string]string
+	if _, err := store.Load("calendar", &value); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	if err := store.Save("calendar", map[string]string{"id": "1"}, time.Now()); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+	if err := store.Delete("calendar"); err != nil {
+		t.Fatalf("Failed to delete snapshot: %v", err)
+	}
+
+	var value map[string]string
+	if _, err := store.Load("calendar", &value); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the snapshot to be gone, got %v", err)
+	}
+	if err := store.Delete("calendar"); err != nil {
+		t.Errorf("Expected deleting a missing snapshot to succeed, got %v", err)
+	}
+}
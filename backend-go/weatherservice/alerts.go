@@ -0,0 +1,99 @@
+package weatherservice
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+// Values of models.WeatherAlert.Severity, most severe first
+var alertSeverities = []string{"extreme", "severe", "moderate", "minor", "unknown"}
+
+// Events warning of immediate danger to life
+var extremeAlertEvents = []string{"tornado warning", "hurricane warning", "extreme wind warning", "tsunami warning", "flash flood emergency"}
+
+// alertSeverity guesses the severity of an alert from its event name, for
+// providers like OpenWeatherMap that don't report one
+func alertSeverity(event string) string {
+	event = strings.ToLower(event)
+	for _, extreme := range extremeAlertEvents {
+		if strings.Contains(event, extreme) {
+			return "extreme"
+		}
+	}
+
+	switch {
+	case strings.Contains(event, "warning") || strings.Contains(event, "emergency"):
+		return "severe"
+	case strings.Contains(event, "watch"):
+		return "moderate"
+	case strings.Contains(event, "advisory") || strings.Contains(event, "statement"):
+		return "minor"
+	}
+	return "unknown"
+}
+
+// normalizeSeverity lowercases a reported severity, mapping values outside
+// alertSeverities to "unknown"
+func normalizeSeverity(severity string) string {
+	severity = strings.ToLower(severity)
+	for _, s := range alertSeverities {
+		if s == severity {
+			return severity
+		}
+	}
+	return "unknown"
+}
+
+func severityRank(severity string) int {
+	for i, s := range alertSeverities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(alertSeverities)
+}
+
+// dedupeAlerts drops repeated alerts: providers send the same event once per
+// language or per overlapping zone, and the first copy is kept
+func dedupeAlerts(alerts []models.WeatherAlert) []models.WeatherAlert {
+	seen := map[models.WeatherAlert]bool{}
+	deduped := make([]models.WeatherAlert, 0, len(alerts))
+	for _, alert := range alerts {
+		key := models.WeatherAlert{
+			Sender: alert.Sender,
+			Event:  strings.ToLower(alert.Event),
+			Start:  alert.Start,
+			End:    alert.End,
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		deduped = append(deduped, alert)
+	}
+	return deduped
+}
+
+// currentAlerts returns the alerts that haven't expired at now (unix
+// seconds), flagged as active once started, most severe and then earliest
+// first
+func currentAlerts(alerts []models.WeatherAlert, now int64) []models.WeatherAlert {
+	var current []models.WeatherAlert
+	for _, alert := range alerts {
+		if alert.End != 0 && alert.End <= now {
+			continue
+		}
+		alert.Active = alert.Start <= now
+		current = append(current, alert)
+	}
+
+	sort.SliceStable(current, func(i, j int) bool {
+		if ri, rj := severityRank(current[i].Severity), severityRank(current[j].Severity); ri != rj {
+			return ri < rj
+		}
+		return current[i].Start < current[j].Start
+	})
+	return current
+}
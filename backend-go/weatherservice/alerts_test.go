@@ -0,0 +1,127 @@
+package weatherservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+)
+
+func TestAlertSeverity(t *testing.T) {
+	testCases := map[string]string{
+		"Tornado Warning":           "extreme",
+		"Winter Storm Warning":      "severe",
+		"Severe Thunderstorm Watch": "moderate",
+		"Wind Advisory":             "minor",
+		"Special Weather Statement": "minor",
+		"Heat":                      "unknown",
+	}
+
+	for event, expected := range testCases {
+		if got := alertSeverity(event); got != expected {
+			t.Errorf("%s: expected %s, got %s", event, expected, got)
+		}
+	}
+}
+
+func TestDedupeAlerts(t *testing.T) {
+	alerts := []models.WeatherAlert{
+		{Sender: "NWS Upton NY", Event: "Winter Storm Warning", Start: 100, End: 200, Description: "Heavy snow"},
+		{Sender: "NWS Upton NY", Event: "WINTER STORM WARNING", Start: 100, End: 200, Description: "Fuertes nevadas"},
+		{Sender: "NWS Upton NY", Event: "Winter Storm Warning", Start: 200, End: 300, Description: "Heavy snow, extended"},
+		{Sender: "NWS Upton NY", Event: "Wind Advisory", Start: 100, End: 200},
+	}
+
+	deduped := dedupeAlerts(alerts)
+
+	if len(deduped) != 3 {
+		t.Fatalf("Expected 3 alerts, got %d", len(deduped))
+	}
+	if deduped[0].Description != "Heavy snow" {
+		t.Errorf("Expected the first copy to be kept, got %q", deduped[0].Description)
+	}
+}
+
+func TestCurrentAlerts(t *testing.T) {
+	alerts := []models.WeatherAlert{
+		{Event: "Wind Advisory", Start: 100, End: 400, Severity: "minor"},
+		{Event: "Flood Watch", Start: 50, End: 150, Severity: "moderate"},
+		{Event: "Winter Storm Warning", Start: 300, End: 500, Severity: "severe"},
+		{Event: "Drought Statement", Start: 10, Severity: "minor"},
+	}
+
+	current := currentAlerts(alerts, 200)
+
+	var events []string
+	for _, alert := range current {
+		events = append(events, alert.Event)
+	}
+	if want := "Winter Storm Warning,Drought Statement,Wind Advisory"; strings.Join(events, ",") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(events, ","))
+	}
+	if current[0].Active || !current[1].Active || !current[2].Active {
+		t.Errorf("Expected only started alerts to be active, got %+v", current)
+	}
+}
+
+func TestOWMAlerts(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(`{
+			"current": {"temp": 1, "weather": [{"id": 601, "main": "Snow"}]},
+			"daily": [{"temp": {"min": -2, "max": 2}, "weather": [{"id": 601, "main": "Snow"}]}],
+			"alerts": [
+				{"sender_name": "NWS Upton NY", "event": "Winter Storm Warning", "start": 1704931200, "end": 1704996000, "description": "Heavy snow", "tags": ["Snow"]}
+			]
+		}`))
+	}))
+	defer ts.Close()
+
+	provider := &owmProvider{retryConfig: retry.DefaultConfig(), baseURL: ts.URL}
+	weatherData, err := provider.Weather(context.Background(), ts.Client())
+	if err != nil {
+		t.Fatalf("Failed to fetch weather: %v", err)
+	}
+
+	if strings.Contains(query, "alerts") {
+		t.Errorf("Expected alerts to be requested, got %s", query)
+	}
+	if len(weatherData.Alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(weatherData.Alerts))
+	}
+	if alert := weatherData.Alerts[0]; alert.Sender != "NWS Upton NY" || alert.Severity != "severe" || alert.End != 1704996000 {
+		t.Errorf("Expected the winter storm warning, got %+v", alert)
+	}
+}
+
+func TestHandleGetWeatherDropsExpiredAlerts(t *testing.T) {
+	now := time.Unix(1704950000, 0)
+	svc := &Service{
+		now: func() time.Time { return now },
+		current: &models.WeatherData{
+			FetchedAt: now.Add(-time.Hour).Unix(),
+			Alerts: []models.WeatherAlert{
+				{Event: "Wind Advisory", Start: 1704913920, End: 1704942000, Severity: "minor"},
+				{Event: "Winter Storm Warning", Start: 1704931200, End: 1704996000, Severity: "severe"},
+			},
+		},
+	}
+
+	w := httptest.NewRecorder()
+	svc.HandleGetWeather(w, httptest.NewRequest(http.MethodGet, "/api/weather", nil))
+
+	var response models.WeatherData
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Alerts) != 1 || response.Alerts[0].Event != "Winter Storm Warning" || !response.Alerts[0].Active {
+		t.Errorf("Expected only the active winter storm warning, got %+v", response.Alerts)
+	}
+}
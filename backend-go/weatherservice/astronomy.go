@@ -0,0 +1,49 @@
+package weatherservice
+
+import (
+	"math"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+// Names of the eight moon phases, starting from the new moon
+var moonPhaseNames = []string{
+	"New Moon",
+	"Waxing Crescent",
+	"First Quarter",
+	"Waxing Gibbous",
+	"Full Moon",
+	"Waning Gibbous",
+	"Last Quarter",
+	"Waning Crescent",
+}
+
+// moonPhaseName names a moon phase given as a fraction of the lunar cycle,
+// where 0 and 1 are new moon and 0.5 full moon. Each name covers an eighth of
+// the cycle centered on its phase.
+func moonPhaseName(phase float64) string {
+	phase -= math.Floor(phase)
+	index := int(math.Floor(phase*8+0.5)) % len(moonPhaseNames)
+	return moonPhaseNames[index]
+}
+
+// daylightSeconds returns the time between sunrise and sunset, 0 when either
+// is missing as during polar day or night
+func daylightSeconds(sunrise, sunset int64) int64 {
+	if sunrise == 0 || sunset <= sunrise {
+		return 0
+	}
+	return sunset - sunrise
+}
+
+func dailyAstronomy(daily models.OWMDailyForecast) *models.Astronomy {
+	return &models.Astronomy{
+		Sunrise:         daily.Sunrise,
+		Sunset:          daily.Sunset,
+		DaylightSeconds: daylightSeconds(daily.Sunrise, daily.Sunset),
+		Moonrise:        daily.Moonrise,
+		Moonset:         daily.Moonset,
+		MoonPhase:       daily.MoonPhase,
+		MoonPhaseName:   moonPhaseName(daily.MoonPhase),
+	}
+}
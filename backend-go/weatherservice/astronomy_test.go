@@ -0,0 +1,77 @@
+package weatherservice
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+func TestMoonPhaseName(t *testing.T) {
+	testCases := map[float64]string{
+		0:     "New Moon",
+		0.05:  "New Moon",
+		0.125: "Waxing Crescent",
+		0.25:  "First Quarter",
+		0.4:   "Waxing Gibbous",
+		0.5:   "Full Moon",
+		0.62:  "Waning Gibbous",
+		0.75:  "Last Quarter",
+		0.85:  "Waning Crescent",
+		0.97:  "New Moon",
+		1:     "New Moon",
+	}
+
+	for phase, expected := range testCases {
+		if got := moonPhaseName(phase); got != expected {
+			t.Errorf("%v: expected %s, got %s", phase, expected, got)
+		}
+	}
+}
+
+func TestDaylightSeconds(t *testing.T) {
+	if got := daylightSeconds(1704974400, 1705008600); got != 34200 {
+		t.Errorf("Expected 34200 seconds of daylight, got %d", got)
+	}
+	if got := daylightSeconds(0, 0); got != 0 {
+		t.Errorf("Expected no daylight during polar night, got %d", got)
+	}
+}
+
+func TestMapOWMSunMoonAndPrecipitation(t *testing.T) {
+	var owmData models.OWMWeatherData
+	err := json.Unmarshal([]byte(`{
+		"current": {"temp": 2, "sunrise": 1704974400, "sunset": 1705008600, "weather": [{"id": 601, "main": "Snow"}], "snow": {"1h": 0.6}},
+		"hourly": [{"dt": 1704996000, "weather": [{"id": 601, "main": "Snow"}], "pop": 0.9, "snow": {"1h": 1.2}, "uvi": 0.4}],
+		"daily": [{
+			"dt": 1704992400, "sunrise": 1704974460, "sunset": 1705008540, "moonrise": 1704981000, "moonset": 1705015000,
+			"moon_phase": 0.5, "summary": "Expect a day of snow", "temp": {"day": 1, "min": -3, "max": 2},
+			"weather": [{"id": 601, "main": "Snow"}], "pop": 1, "rain": 0.5, "snow": 8.3, "uvi": 1.1
+		}]
+	}`), &owmData)
+	if err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+
+	weatherData := (&owmProvider{}).mapToWeatherData(&owmData)
+
+	if weatherData.Snow != 0.6 || weatherData.Pop != 1 {
+		t.Errorf("Expected the current snow and today's pop, got snow %v pop %v", weatherData.Snow, weatherData.Pop)
+	}
+	if astronomy := weatherData.Astronomy; astronomy == nil || astronomy.Sunrise != 1704974400 || astronomy.DaylightSeconds != 34200 || astronomy.MoonPhaseName != "Full Moon" {
+		t.Errorf("Expected today's sun and moon, got %+v", astronomy)
+	}
+
+	hourly := weatherData.HourlyForecast[0]
+	if hourly.Pop != 0.9 || hourly.Snow != 1.2 || hourly.UVI != 0.4 || hourly.Astronomy != nil {
+		t.Errorf("Expected hourly precipitation and UV, got %+v", hourly)
+	}
+
+	daily := weatherData.DailyForecast[0]
+	if daily.Summary != "Expect a day of snow" || daily.Rain != 0.5 || daily.Snow != 8.3 || daily.UVI != 1.1 {
+		t.Errorf("Expected daily summary, precipitation and UV, got %+v", daily)
+	}
+	if astronomy := daily.Astronomy; astronomy == nil || astronomy.Moonrise != 1704981000 || astronomy.MoonPhase != 0.5 || astronomy.DaylightSeconds != 34080 {
+		t.Errorf("Expected the day's sun and moon, got %+v", astronomy)
+	}
+}
@@ -0,0 +1,130 @@
+package weatherservice
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+// Intensity from which a minute counts as precipitating, in mm/h. Lower
+// values are drizzle too light to notice.
+const precipitationThreshold = 0.1
+
+// Length of the nowcast window
+const nowcastWindow = 60 * 60
+
+// precipitationIntensity names an intensity in mm/h, following the usual
+// rain rate classes
+func precipitationIntensity(mmPerHour float64) string {
+	switch {
+	case mmPerHour < 2.5:
+		return "light"
+	case mmPerHour < 7.6:
+		return "moderate"
+	case mmPerHour < 50:
+		return "heavy"
+	}
+	return "very heavy"
+}
+
+// nowcastAt summarizes the minutes of the hour following now (unix seconds).
+// The summary is worded relative to now, so it is computed when the weather is
+// served rather than when it was fetched. status is the current condition
+// group, deciding whether the summary talks about rain or snow. It returns
+// nil when no minute of the hour is known. A snapshot served some time after
+// it was fetched covers less than the hour, and the summary says so.
+func nowcastAt(nowcast *models.Nowcast, status string, now int64) *models.Nowcast {
+	if nowcast == nil {
+		return nil
+	}
+
+	// The current minute started up to a minute ago
+	var minutes []models.NowcastMinute
+	for _, minute := range nowcast.Minutes {
+		if minute.Timestamp > now-60 && minute.Timestamp < now+nowcastWindow {
+			minutes = append(minutes, minute)
+		}
+	}
+	if len(minutes) == 0 {
+		return nil
+	}
+
+	result := &models.Nowcast{
+		Precipitating: minutes[0].Precipitation >= precipitationThreshold,
+		Minutes:       minutes,
+	}
+
+	raining := result.Precipitating
+	for _, minute := range minutes {
+		if minute.Precipitation > result.PeakIntensity {
+			result.PeakIntensity = minute.Precipitation
+		}
+
+		wet := minute.Precipitation >= precipitationThreshold
+		switch {
+		case wet && !raining && result.StartsAt == 0:
+			result.StartsAt = minute.Timestamp
+		case !wet && raining && result.StopsAt == 0:
+			result.StopsAt = minute.Timestamp
+		}
+		// Only the first spell of precipitation is described
+		if result.StopsAt == 0 {
+			raining = wet
+		}
+	}
+
+	// The last known minute ends the period the summary can speak for
+	end := minutes[len(minutes)-1].Timestamp + 60
+	result.Summary = nowcastSummary(result, status, now, end)
+	return result
+}
+
+func nowcastSummary(nowcast *models.Nowcast, status string, now, end int64) string {
+	kind := "rain"
+	if status == "Snow" {
+		kind = "snow"
+	}
+
+	if !nowcast.Precipitating && nowcast.StartsAt == 0 {
+		return fmt.Sprintf("No %s for the next %s", kind, nowcastPeriod(now, end))
+	}
+
+	intensity := precipitationIntensity(nowcast.PeakIntensity)
+	description := strings.ToUpper(intensity[:1]) + intensity[1:] + " " + kind
+
+	if nowcast.Precipitating {
+		if nowcast.StopsAt == 0 {
+			return description + " for the next " + nowcastPeriod(now, end)
+		}
+		return fmt.Sprintf("%s stopping in %s", description, minutesFrom(now, nowcast.StopsAt))
+	}
+
+	if nowcast.StopsAt == 0 {
+		return fmt.Sprintf("%s starting in %s", description, minutesFrom(now, nowcast.StartsAt))
+	}
+	return fmt.Sprintf("%s starting in %s, lasting %s", description, minutesFrom(now, nowcast.StartsAt), minutesFrom(nowcast.StartsAt, nowcast.StopsAt))
+}
+
+// nowcastPeriod names the period from now until end, the end of the last
+// known minute. Partial minutes are left out so the period never claims more
+// than the data covers.
+func nowcastPeriod(now, end int64) string {
+	minutes := (end - now) / 60
+	switch {
+	case minutes >= nowcastWindow/60:
+		return "hour"
+	case minutes <= 1:
+		return "minute"
+	}
+	return fmt.Sprintf("%d minutes", minutes)
+}
+
+// minutesFrom formats the whole minutes between two unix times, at least 1
+func minutesFrom(from, to int64) string {
+	minutes := (to - from + 59) / 60
+	if minutes <= 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", minutes)
+}
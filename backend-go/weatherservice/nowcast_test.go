@@ -0,0 +1,150 @@
+package weatherservice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+)
+
+// minutesFromNow builds a minutely series starting at now from intensities
+func minutesFromNow(now int64, precipitation ...float64) *models.Nowcast {
+	nowcast := &models.Nowcast{}
+	for i, p := range precipitation {
+		nowcast.Minutes = append(nowcast.Minutes, models.NowcastMinute{Timestamp: now + int64(i)*60, Precipitation: p})
+	}
+	return nowcast
+}
+
+func repeat(value float64, count int) []float64 {
+	values := make([]float64, count)
+	for i := range values {
+		values[i] = value
+	}
+	return values
+}
+
+func TestNowcastAt(t *testing.T) {
+	const now = 1704950000
+
+	testCases := []struct {
+		name          string
+		precipitation []float64
+		status        string
+		summary       string
+	}{
+		{"dry", repeat(0, 60), "Clouds", "No rain for the next hour"},
+		{"trace amounts", repeat(0.05, 60), "Clouds", "No rain for the next hour"},
+		{"starting", append(repeat(0, 15), repeat(1.2, 45)...), "Clouds", "Light rain starting in 15 minutes"},
+		{"stopping", append(repeat(4, 20), repeat(0, 40)...), "Rain", "Moderate rain stopping in 20 minutes"},
+		{"continuing", repeat(12, 60), "Rain", "Heavy rain for the next hour"},
+		{"shower", append(append(repeat(0, 10), repeat(60, 25)...), repeat(0, 25)...), "Clouds", "Very heavy rain starting in 10 minutes, lasting 25 minutes"},
+		{"snow", append(repeat(0, 1), repeat(0.5, 59)...), "Snow", "Light snow starting in 1 minute"},
+	}
+
+	for _, tc := range testCases {
+		nowcast := nowcastAt(minutesFromNow(now, tc.precipitation...), tc.status, now)
+		if nowcast == nil {
+			t.Fatalf("%s: expected a nowcast", tc.name)
+		}
+		if nowcast.Summary != tc.summary {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.summary, nowcast.Summary)
+		}
+	}
+}
+
+func TestNowcastAtServeTime(t *testing.T) {
+	const fetched = 1704950000
+	nowcast := minutesFromNow(fetched, append(repeat(0, 30), repeat(1, 31)...)...)
+
+	// Ten minutes after the fetch the rain is twenty minutes away
+	later := nowcastAt(nowcast, "Clouds", fetched+600)
+	if later.Summary != "Light rain starting in 20 minutes" || later.StartsAt != fetched+1800 {
+		t.Errorf("Expected rain in 20 minutes, got %+v", later)
+	}
+	if len(later.Minutes) != 51 || later.Minutes[0].Timestamp != fetched+600 {
+		t.Errorf("Expected the past minutes to be dropped, got %d minutes", len(later.Minutes))
+	}
+	if len(nowcast.Minutes) != 61 {
+		t.Errorf("Expected the stored nowcast to be left alone, got %d minutes", len(nowcast.Minutes))
+	}
+
+	if expired := nowcastAt(nowcast, "Clouds", fetched+2*3600); expired != nil {
+		t.Errorf("Expected no nowcast once the series is over, got %+v", expired)
+	}
+	if nowcastAt(nil, "Clouds", fetched) != nil {
+		t.Error("Expected no nowcast without minutely data")
+	}
+}
+
+func TestNowcastFromOldSnapshot(t *testing.T) {
+	const fetched = 1704950000
+	served := time.Unix(fetched+15*60, 0)
+
+	testCases := []struct {
+		precipitation []float64
+		summary       string
+	}{
+		{repeat(0, 60), "No rain for the next 45 minutes"},
+		{repeat(12, 60), "Heavy rain for the next 45 minutes"},
+	}
+
+	for _, tc := range testCases {
+		svc := &Service{
+			now: func() time.Time { return served },
+			current: &models.WeatherData{
+				Status:    "Rain",
+				FetchedAt: fetched,
+				Nowcast:   minutesFromNow(fetched, tc.precipitation...),
+			},
+		}
+
+		weatherData, ok := svc.currentWeather()
+		if !ok || weatherData.Nowcast == nil {
+			t.Fatalf("Expected a nowcast, got %+v", weatherData)
+		}
+		if weatherData.Nowcast.Summary != tc.summary {
+			t.Errorf("Expected %q, got %q", tc.summary, weatherData.Nowcast.Summary)
+		}
+		if len(weatherData.Nowcast.Minutes) != 45 {
+			t.Errorf("Expected the 45 remaining minutes, got %d", len(weatherData.Nowcast.Minutes))
+		}
+	}
+}
+
+func TestOWMNowcast(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(`{
+			"current": {"temp": 10, "weather": [{"id": 500, "main": "Rain"}]},
+			"daily": [{"temp": {"min": 5, "max": 12}, "weather": [{"id": 500, "main": "Rain"}]}],
+			"minutely": [
+				{"dt": 1704950040, "precipitation": 0.8},
+				{"dt": 1704950100, "precipitation": 0}
+			]
+		}`))
+	}))
+	defer ts.Close()
+
+	provider := &owmProvider{retryConfig: retry.DefaultConfig(), baseURL: ts.URL}
+	weatherData, err := provider.Weather(context.Background(), ts.Client())
+	if err != nil {
+		t.Fatalf("Failed to fetch weather: %v", err)
+	}
+
+	if strings.Contains(query, "minutely") {
+		t.Errorf("Expected minutely data to be requested, got %s", query)
+	}
+	if weatherData.Nowcast == nil || len(weatherData.Nowcast.Minutes) != 2 {
+		t.Fatalf("Expected 2 minutes, got %+v", weatherData.Nowcast)
+	}
+	if minute := weatherData.Nowcast.Minutes[0]; minute.Timestamp != 1704950040 || minute.Precipitation != 0.8 {
+		t.Errorf("Expected the first minute, got %+v", minute)
+	}
+}
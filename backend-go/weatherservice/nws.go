@@ -0,0 +1,348 @@
+package weatherservice
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+)
+
+// US National Weather Service API
+const nwsBaseURL = "https://api.weather.gov"
+
+// User-Agent sent to the NWS API when none is configured
+const defaultNWSUserAgent = "piinky (https://github.com/coreyk/piinky)"
+
+// Number of hourly periods kept, as many as One Call returns
+const nwsForecastHours = 48
+
+// nwsProvider reads the US National Weather Service API, which needs no key
+// but only covers the United States. The forecast offices and grid of a
+// location are looked up first, then its forecasts.
+type nwsProvider struct {
+	settings    models.WeatherSettings
+	retryConfig retry.Config
+	baseURL     string
+}
+
+// nwsPoint is the subset of a /points response we read
+type nwsPoint struct {
+	Properties struct {
+		Forecast       string `json:"forecast"`
+		ForecastHourly string `json:"forecastHourly"`
+	} `json:"properties"`
+}
+
+// nwsForecast is the subset of a gridpoint forecast we read
+type nwsForecast struct {
+	Properties struct {
+		Periods []nwsPeriod `json:"periods"`
+	} `json:"properties"`
+}
+
+type nwsPeriod struct {
+	StartTime        time.Time `json:"startTime"`
+	IsDaytime        bool      `json:"isDaytime"`
+	Temperature      float64   `json:"temperature"`
+	WindSpeed        string    `json:"windSpeed"` // e.g. "10 mph" or "5 to 10 km/h"
+	WindDirection    string    `json:"windDirection"`
+	Icon             string    `json:"icon"`
+	ShortForecast    string    `json:"shortForecast"`
+	RelativeHumidity struct {
+		Value float64 `json:"value"`
+	} `json:"relativeHumidity"`
+}
+
+// nwsAlerts is the subset of an /alerts/active response we read
+type nwsAlerts struct {
+	Features []struct {
+		Properties struct {
+			SenderName  string     `json:"senderName"`
+			Event       string     `json:"event"`
+			Effective   time.Time  `json:"effective"`
+			Onset       *time.Time `json:"onset"`
+			Expires     time.Time  `json:"expires"`
+			Ends        *time.Time `json:"ends"`
+			Description string     `json:"description"`
+			Severity    string     `json:"severity"`
+		} `json:"properties"`
+	} `json:"features"`
+}
+
+// nwsIcons maps NWS icon names to OpenWeatherMap condition codes
+var nwsIcons = map[string]int{
+	"skc":             800,
+	"few":             801,
+	"sct":             802,
+	"bkn":             803,
+	"ovc":             804,
+	"wind_skc":        800,
+	"wind_few":        801,
+	"wind_sct":        802,
+	"wind_bkn":        803,
+	"wind_ovc":        804,
+	"snow":            601,
+	"rain_snow":       616,
+	"rain_sleet":      611,
+	"snow_sleet":      611,
+	"sleet":           611,
+	"fzra":            511,
+	"rain_fzra":       511,
+	"snow_fzra":       511,
+	"rain":            501,
+	"rain_showers":    521,
+	"rain_showers_hi": 520,
+	"tsra":            211,
+	"tsra_sct":        210,
+	"tsra_hi":         210,
+	"tornado":         781,
+	"hurricane":       781,
+	"tropical_storm":  771,
+	"dust":            761,
+	"smoke":           711,
+	"haze":            721,
+	"hot":             800,
+	"cold":            800,
+	"blizzard":        602,
+	"fog":             741,
+}
+
+// compassDegrees maps wind directions to degrees
+var compassDegrees = map[string]float64{
+	"N": 0, "NNE": 22.5, "NE": 45, "ENE": 67.5,
+	"E": 90, "ESE": 112.5, "SE": 135, "SSE": 157.5,
+	"S": 180, "SSW": 202.5, "SW": 225, "WSW": 247.5,
+	"W": 270, "WNW": 292.5, "NW": 315, "NNW": 337.5,
+}
+
+func (p *nwsProvider) Weather(ctx context.Context, httpClient HTTPClient) (models.WeatherData, error) {
+	userAgent := p.settings.UserAgent
+	if userAgent == "" {
+		userAgent = defaultNWSUserAgent
+	}
+	header := http.Header{
+		"User-Agent": {userAgent},
+		"Accept":     {"application/geo+json"},
+	}
+
+	var point nwsPoint
+	pointURL := fmt.Sprintf("%s/points/%.4f,%.4f", p.baseURL, p.settings.Lat, p.settings.Lon)
+	if err := getJSON(ctx, httpClient, p.retryConfig, "NWS", pointURL, header, &point); err != nil {
+		return models.WeatherData{}, err
+	}
+	if point.Properties.Forecast == "" || point.Properties.ForecastHourly == "" {
+		return models.WeatherData{}, fmt.Errorf("NWS has no forecast for %.4f,%.4f", p.settings.Lat, p.settings.Lon)
+	}
+
+	// NWS forecasts are in US units unless SI units are asked for
+	units := "us"
+	if p.settings.Units != "imperial" {
+		units = "si"
+	}
+
+	var hourly, daily nwsForecast
+	if err := getJSON(ctx, httpClient, p.retryConfig, "NWS", withUnits(point.Properties.ForecastHourly, units), header, &hourly); err != nil {
+		return models.WeatherData{}, err
+	}
+	if err := getJSON(ctx, httpClient, p.retryConfig, "NWS", withUnits(point.Properties.Forecast, units), header, &daily); err != nil {
+		return models.WeatherData{}, err
+	}
+
+	weatherData, err := p.weatherData(hourly.Properties.Periods, daily.Properties.Periods)
+	if err != nil {
+		return models.WeatherData{}, err
+	}
+
+	// The forecast is still worth showing when the alerts can't be fetched
+	var alerts nwsAlerts
+	alertsURL := fmt.Sprintf("%s/alerts/active?point=%.4f,%.4f", p.baseURL, p.settings.Lat, p.settings.Lon)
+	if err := getJSON(ctx, httpClient, p.retryConfig, "NWS", alertsURL, header, &alerts); err != nil {
+		log.Printf("Failed to fetch NWS alerts, serving the weather without them: %v", err)
+		return weatherData, nil
+	}
+	weatherData.Alerts = alerts.weatherAlerts()
+	return weatherData, nil
+}
+
+// weatherAlerts maps the alerts, from their onset (or when they were issued)
+// until their end (or when they expire)
+func (a nwsAlerts) weatherAlerts() []models.WeatherAlert {
+	var alerts []models.WeatherAlert
+	for _, feature := range a.Features {
+		props := feature.Properties
+		start, end := props.Effective, props.Expires
+		if props.Onset != nil {
+			start = *props.Onset
+		}
+		if props.Ends != nil {
+			end = *props.Ends
+		}
+
+		alert := models.WeatherAlert{
+			Sender:      props.SenderName,
+			Event:       props.Event,
+			Start:       start.Unix(),
+			Description: props.Description,
+			Severity:    normalizeSeverity(props.Severity),
+		}
+		if !end.IsZero() {
+			alert.End = end.Unix()
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// withUnits adds the units parameter to a forecast URL
+func withUnits(forecastURL, units string) string {
+	u, err := url.Parse(forecastURL)
+	if err != nil {
+		return forecastURL
+	}
+	query := u.Query()
+	query.Set("units", units)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func (p *nwsProvider) weatherData(hourly, daily []nwsPeriod) (models.WeatherData, error) {
+	if len(hourly) == 0 || len(daily) == 0 {
+		return models.WeatherData{}, fmt.Errorf("NWS forecast has no periods")
+	}
+
+	// The first hourly period is the current hour
+	current := hourly[0]
+	icon := nwsIconCondition(current.Icon)
+	weatherData := models.WeatherData{
+		Latitude:  p.settings.Lat,
+		Longitude: p.settings.Lon,
+		Temperature: models.TemperatureData{
+			Temp:      current.Temperature,
+			FeelsLike: current.Temperature,
+		},
+		Status:         conditionMain(icon),
+		DetailedStatus: current.ShortForecast,
+		Icon:           icon,
+		Humidity:       current.RelativeHumidity.Value,
+		WindSpeed:      p.windSpeed(current.WindSpeed),
+		WindDir:        compassDegrees[current.WindDirection],
+		Summary:        conditionMain(icon),
+	}
+
+	weatherData.HourlyForecast = make([]models.ForecastData, 0, nwsForecastHours)
+	for _, period := range hourly {
+		if len(weatherData.HourlyForecast) == nwsForecastHours {
+			break
+		}
+		condition := nwsIconCondition(period.Icon)
+		weatherData.HourlyForecast = append(weatherData.HourlyForecast, models.ForecastData{
+			Timestamp: period.StartTime.Unix(),
+			Temperature: models.TemperatureData{
+				Temp:      period.Temperature,
+				FeelsLike: period.Temperature,
+			},
+			Status:    conditionMain(condition),
+			Icon:      condition,
+			Humidity:  int(period.RelativeHumidity.Value),
+			WindSpeed: p.windSpeed(period.WindSpeed),
+		})
+	}
+
+	weatherData.DailyForecast = nwsDailyForecast(daily)
+	today := weatherData.DailyForecast[0].Temperature
+	weatherData.Temperature.TempMin = today.TempMin
+	weatherData.Temperature.TempMax = today.TempMax
+
+	return weatherData, nil
+}
+
+// nwsDailyForecast combines the day and night periods of each date. The
+// forecast may start with tonight, which then stands for today.
+func nwsDailyForecast(periods []nwsPeriod) []models.ForecastData {
+	var forecast []models.ForecastData
+	byDate := map[string]int{} // Index in forecast
+	for _, period := range periods {
+		date := period.StartTime.Format("2006-01-02")
+		condition := nwsIconCondition(period.Icon)
+
+		i, ok := byDate[date]
+		if !ok {
+			byDate[date] = len(forecast)
+			forecast = append(forecast, models.ForecastData{
+				Timestamp: period.StartTime.Unix(),
+				Temperature: models.TemperatureData{
+					Temp:      period.Temperature,
+					TempMin:   float64Ptr(period.Temperature),
+					TempMax:   float64Ptr(period.Temperature),
+					FeelsLike: period.Temperature,
+				},
+				Status: conditionMain(condition),
+				Icon:   condition,
+			})
+			continue
+		}
+
+		day := &forecast[i]
+		if period.Temperature < *day.Temperature.TempMin {
+			*day.Temperature.TempMin = period.Temperature
+		}
+		if period.Temperature > *day.Temperature.TempMax {
+			*day.Temperature.TempMax = period.Temperature
+		}
+		if period.IsDaytime {
+			// The daytime period describes the day
+			day.Temperature.Temp = period.Temperature
+			day.Temperature.FeelsLike = period.Temperature
+			day.Status = conditionMain(condition)
+			day.Icon = condition
+		}
+	}
+	return forecast
+}
+
+// nwsIconCondition maps an NWS icon URL, e.g.
+// https://api.weather.gov/icons/land/day/tsra_sct,40/rain,20?size=medium, to
+// an OpenWeatherMap condition code using its first condition
+func nwsIconCondition(iconURL string) int {
+	u, err := url.Parse(iconURL)
+	if err != nil {
+		return 803
+	}
+
+	// The conditions follow the "day" or "night" segment
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i] != "day" && segments[i] != "night" {
+			continue
+		}
+		name, _, _ := strings.Cut(segments[i+1], ",")
+		if id, ok := nwsIcons[name]; ok {
+			return id
+		}
+	}
+	return 803
+}
+
+// windSpeed parses an NWS wind speed like "10 mph" or "5 to 10 km/h", using
+// the highest speed. Speeds are converted to m/s for metric units, matching
+// OpenWeatherMap.
+func (p *nwsProvider) windSpeed(s string) float64 {
+	fields := strings.Fields(s)
+	var speed float64
+	for _, field := range fields {
+		if v, err := strconv.ParseFloat(field, 64); err == nil && v > speed {
+			speed = v
+		}
+	}
+	if len(fields) > 0 && fields[len(fields)-1] == "km/h" {
+		return speed / 3.6
+	}
+	return speed
+}
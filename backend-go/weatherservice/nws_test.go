@@ -0,0 +1,282 @@
+package weatherservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+)
+
+const testNWSHourly = `{
+  "properties": {
+    "periods": [
+      {
+        "number": 1,
+        "startTime": "2024-01-10T15:00:00-05:00",
+        "isDaytime": true,
+        "temperature": 41,
+        "temperatureUnit": "F",
+        "windSpeed": "10 to 15 mph",
+        "windDirection": "SW",
+        "icon": "https://api.weather.gov/icons/land/day/rain,60?size=small",
+        "shortForecast": "Chance Light Rain",
+        "relativeHumidity": {"unitCode": "wmoUnit:percent", "value": 86}
+      },
+      {
+        "number": 2,
+        "startTime": "2024-01-10T16:00:00-05:00",
+        "isDaytime": true,
+        "temperature": 40,
+        "temperatureUnit": "F",
+        "windSpeed": "12 mph",
+        "windDirection": "WSW",
+        "icon": "https://api.weather.gov/icons/land/day/tsra_sct,40/rain,20?size=small",
+        "shortForecast": "Chance Showers And Thunderstorms",
+        "relativeHumidity": {"unitCode": "wmoUnit:percent", "value": 88}
+      }
+    ]
+  }
+}`
+
+const testNWSForecast = `{
+  "properties": {
+    "periods": [
+      {
+        "name": "This Afternoon",
+        "startTime": "2024-01-10T14:00:00-05:00",
+        "isDaytime": true,
+        "temperature": 44,
+        "windSpeed": "10 to 15 mph",
+        "windDirection": "SW",
+        "icon": "https://api.weather.gov/icons/land/day/rain,60?size=medium",
+        "shortForecast": "Chance Light Rain"
+      },
+      {
+        "name": "Tonight",
+        "startTime": "2024-01-10T18:00:00-05:00",
+        "isDaytime": false,
+        "temperature": 33,
+        "windSpeed": "5 mph",
+        "windDirection": "W",
+        "icon": "https://api.weather.gov/icons/land/night/bkn?size=medium",
+        "shortForecast": "Mostly Cloudy"
+      },
+      {
+        "name": "Thursday",
+        "startTime": "2024-01-11T06:00:00-05:00",
+        "isDaytime": true,
+        "temperature": 38,
+        "windSpeed": "5 mph",
+        "windDirection": "NW",
+        "icon": "https://api.weather.gov/icons/land/day/skc?size=medium",
+        "shortForecast": "Sunny"
+      },
+      {
+        "name": "Thursday Night",
+        "startTime": "2024-01-11T18:00:00-05:00",
+        "isDaytime": false,
+        "temperature": 27,
+        "windSpeed": "5 mph",
+        "windDirection": "NW",
+        "icon": "https://api.weather.gov/icons/land/night/snow,30?size=medium",
+        "shortForecast": "Slight Chance Snow"
+      }
+    ]
+  }
+}`
+
+const testNWSAlerts = `{
+  "type": "FeatureCollection",
+  "features": [
+    {
+      "properties": {
+        "senderName": "NWS Upton NY",
+        "event": "Winter Storm Warning",
+        "effective": "2024-01-10T14:12:00-05:00",
+        "onset": "2024-01-10T19:00:00-05:00",
+        "expires": "2024-01-11T04:15:00-05:00",
+        "ends": "2024-01-11T13:00:00-05:00",
+        "severity": "Severe",
+        "description": "Heavy snow expected. Total snow accumulations of 6 to 10 inches."
+      }
+    },
+    {
+      "properties": {
+        "senderName": "NWS Upton NY",
+        "event": "Wind Advisory",
+        "effective": "2024-01-10T14:12:00-05:00",
+        "onset": null,
+        "expires": "2024-01-10T22:00:00-05:00",
+        "ends": null,
+        "severity": "Moderate",
+        "description": "Southwest winds 20 to 30 mph with gusts up to 50 mph."
+      }
+    }
+  ]
+}`
+
+// newNWSServer serves the points, hourly forecast, forecast and alerts
+// endpoints of the NWS API
+func newNWSServer(t *testing.T, requests *[]string) *httptest.Server {
+	return newNWSServerWithAlertsStatus(t, requests, http.StatusOK)
+}
+
+// newNWSServerWithAlertsStatus is newNWSServer with the alerts endpoint
+// answering alertsStatus
+func newNWSServerWithAlertsStatus(t *testing.T, requests *[]string, alertsStatus int) *httptest.Server {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.RequestURI())
+		if r.Header.Get("User-Agent") == "" {
+			http.Error(w, "missing User-Agent", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/geo+json")
+		switch r.URL.Path {
+		case "/points/40.7851,-73.9683":
+			fmt.Fprintf(w, `{"properties": {"forecast": "%[1]s/gridpoints/OKX/33,37/forecast", "forecastHourly": "%[1]s/gridpoints/OKX/33,37/forecast/hourly"}}`, ts.URL)
+		case "/gridpoints/OKX/33,37/forecast/hourly":
+			w.Write([]byte(testNWSHourly))
+		case "/gridpoints/OKX/33,37/forecast":
+			w.Write([]byte(testNWSForecast))
+		case "/alerts/active":
+			if alertsStatus != http.StatusOK {
+				http.Error(w, "alerts unavailable", alertsStatus)
+				return
+			}
+			if r.URL.Query().Get("point") != "40.7851,-73.9683" {
+				http.Error(w, "unexpected point", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(testNWSAlerts))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return ts
+}
+
+func TestNWSProvider(t *testing.T) {
+	var requests []string
+	ts := newNWSServer(t, &requests)
+	defer ts.Close()
+
+	provider := &nwsProvider{
+		settings:    models.WeatherSettings{Lat: 40.785091, Lon: -73.968285, Units: "imperial", UserAgent: "piinky-test (test@example.com)"},
+		retryConfig: retry.DefaultConfig(),
+		baseURL:     ts.URL,
+	}
+	weatherData, err := provider.Weather(context.Background(), ts.Client())
+	if err != nil {
+		t.Fatalf("Failed to fetch weather: %v", err)
+	}
+
+	if len(requests) != 4 || !strings.HasSuffix(requests[1], "?units=us") {
+		t.Errorf("Expected the point, both forecasts in US units and the alerts, got %v", requests)
+	}
+
+	if weatherData.Temperature.Temp != 41 || *weatherData.Temperature.TempMin != 33 || *weatherData.Temperature.TempMax != 44 {
+		t.Errorf("Expected 41 between 33 and 44, got %+v", weatherData.Temperature)
+	}
+	if weatherData.Icon != 501 || weatherData.Status != "Rain" || weatherData.DetailedStatus != "Chance Light Rain" {
+		t.Errorf("Expected light rain (501), got %d %s %q", weatherData.Icon, weatherData.Status, weatherData.DetailedStatus)
+	}
+	if weatherData.Humidity != 86 || weatherData.WindSpeed != 15 || weatherData.WindDir != 225 {
+		t.Errorf("Expected humidity 86 and 15 mph from the SW, got %v %v %v", weatherData.Humidity, weatherData.WindSpeed, weatherData.WindDir)
+	}
+	if weatherData.Latitude != 40.785091 || weatherData.Longitude != -73.968285 {
+		t.Errorf("Expected the configured location, got %v,%v", weatherData.Latitude, weatherData.Longitude)
+	}
+
+	if len(weatherData.HourlyForecast) != 2 {
+		t.Fatalf("Expected 2 hourly forecasts, got %d", len(weatherData.HourlyForecast))
+	}
+	if next := weatherData.HourlyForecast[1]; next.Timestamp != 1704920400 || next.Icon != 210 || next.Status != "Thunderstorm" {
+		t.Errorf("Expected scattered thunderstorms at 16:00, got %+v", next)
+	}
+
+	if len(weatherData.DailyForecast) != 2 {
+		t.Fatalf("Expected day and night combined into 2 days, got %d", len(weatherData.DailyForecast))
+	}
+	if len(weatherData.Alerts) != 2 {
+		t.Fatalf("Expected 2 alerts, got %d", len(weatherData.Alerts))
+	}
+	storm := weatherData.Alerts[0]
+	if storm.Event != "Winter Storm Warning" || storm.Sender != "NWS Upton NY" || storm.Severity != "severe" {
+		t.Errorf("Expected a severe winter storm warning, got %+v", storm)
+	}
+	if storm.Start != 1704931200 || storm.End != 1704996000 {
+		t.Errorf("Expected the storm from its onset to its end, got %d..%d", storm.Start, storm.End)
+	}
+	if wind := weatherData.Alerts[1]; wind.Start != 1704913920 || wind.End != 1704942000 || wind.Severity != "moderate" {
+		t.Errorf("Expected the advisory from when it was issued until it expires, got %+v", wind)
+	}
+
+	if thursday := weatherData.DailyForecast[1]; thursday.Icon != 800 || *thursday.Temperature.TempMax != 38 || *thursday.Temperature.TempMin != 27 {
+		t.Errorf("Expected a sunny Thursday from 27 to 38, got %+v", thursday)
+	}
+}
+
+func TestNWSProviderOutsideUS(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"title": "Data Unavailable For Requested Point"}`, http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	provider := &nwsProvider{
+		settings:    models.WeatherSettings{Lat: 51.5, Lon: -0.12},
+		retryConfig: retry.DefaultConfig(),
+		baseURL:     ts.URL,
+	}
+	if _, err := provider.Weather(context.Background(), ts.Client()); err == nil {
+		t.Error("Expected an error for a location outside the US")
+	}
+}
+
+func TestNWSWindSpeed(t *testing.T) {
+	imperial := &nwsProvider{settings: models.WeatherSettings{Units: "imperial"}}
+	metric := &nwsProvider{settings: models.WeatherSettings{Units: "metric"}}
+
+	if got := imperial.windSpeed("5 to 10 mph"); got != 10 {
+		t.Errorf("Expected 10 mph, got %v", got)
+	}
+	if got := metric.windSpeed("18 km/h"); got != 5 {
+		t.Errorf("Expected 18 km/h to be 5 m/s, got %v", got)
+	}
+	if got := imperial.windSpeed(""); got != 0 {
+		t.Errorf("Expected no wind, got %v", got)
+	}
+}
+
+func TestNWSProviderWithoutAlerts(t *testing.T) {
+	var requests []string
+	ts := newNWSServerWithAlertsStatus(t, &requests, http.StatusInternalServerError)
+	defer ts.Close()
+
+	provider := &nwsProvider{
+		settings:    models.WeatherSettings{Lat: 40.785091, Lon: -73.968285, Units: "imperial"},
+		retryConfig: retry.Config{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		baseURL:     ts.URL,
+	}
+	weatherData, err := provider.Weather(context.Background(), ts.Client())
+	if err != nil {
+		t.Fatalf("Expected the weather despite failing alerts, got %v", err)
+	}
+
+	if weatherData.Temperature.Temp != 41 || len(weatherData.DailyForecast) == 0 {
+		t.Errorf("Expected the current weather and forecast, got %+v", weatherData)
+	}
+	if len(weatherData.Alerts) != 0 {
+		t.Errorf("Expected no alerts, got %+v", weatherData.Alerts)
+	}
+	if len(requests) < 4 || !strings.HasPrefix(requests[len(requests)-1], "/alerts/active") {
+		t.Errorf("Expected the alerts to be requested after the forecasts, got %v", requests)
+	}
+}
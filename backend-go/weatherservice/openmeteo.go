@@ -0,0 +1,210 @@
+package weatherservice
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+)
+
+// Open-Meteo forecast endpoint
+const openMeteoBaseURL = "https://api.open-meteo.com/v1/forecast"
+
+// Number of hours and days of forecast requested from Open-Meteo, as many as
+// One Call returns
+const (
+	openMeteoForecastHours = 48
+	openMeteoForecastDays  = 8
+)
+
+// openMeteoProvider reads the Open-Meteo forecast API, which needs no key
+type openMeteoProvider struct {
+	settings    models.WeatherSettings
+	retryConfig retry.Config
+	baseURL     string
+}
+
+// openMeteoResponse is the subset of an Open-Meteo forecast we read. Times
+// are unix seconds; hourly and daily values are parallel arrays.
+type openMeteoResponse struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Current   struct {
+		Time                int64   `json:"time"`
+		Temperature         float64 `json:"temperature_2m"`
+		ApparentTemperature float64 `json:"apparent_temperature"`
+		RelativeHumidity    float64 `json:"relative_humidity_2m"`
+		WeatherCode         int     `json:"weather_code"`
+		CloudCover          float64 `json:"cloud_cover"`
+		WindSpeed           float64 `json:"wind_speed_10m"`
+		WindDirection       float64 `json:"wind_direction_10m"`
+		UVIndex             float64 `json:"uv_index"`
+	} `json:"current"`
+	Hourly struct {
+		Time                []int64   `json:"time"`
+		Temperature         []float64 `json:"temperature_2m"`
+		ApparentTemperature []float64 `json:"apparent_temperature"`
+		RelativeHumidity    []float64 `json:"relative_humidity_2m"`
+		WeatherCode         []int     `json:"weather_code"`
+		WindSpeed           []float64 `json:"wind_speed_10m"`
+	} `json:"hourly"`
+	Daily struct {
+		Time                   []int64   `json:"time"`
+		WeatherCode            []int     `json:"weather_code"`
+		TemperatureMax         []float64 `json:"temperature_2m_max"`
+		TemperatureMin         []float64 `json:"temperature_2m_min"`
+		ApparentTemperatureMax []float64 `json:"apparent_temperature_max"`
+	} `json:"daily"`
+}
+
+// wmoCondition is the OpenWeatherMap condition code and description of a WMO
+// weather interpretation code
+type wmoCondition struct {
+	id          int
+	description string
+}
+
+var wmoConditions = map[int]wmoCondition{
+	0:  {800, "clear sky"},
+	1:  {801, "mainly clear"},
+	2:  {802, "partly cloudy"},
+	3:  {804, "overcast"},
+	45: {741, "fog"},
+	48: {741, "depositing rime fog"},
+	51: {300, "light drizzle"},
+	53: {301, "drizzle"},
+	55: {302, "heavy drizzle"},
+	56: {511, "light freezing drizzle"},
+	57: {511, "freezing drizzle"},
+	61: {500, "light rain"},
+	63: {501, "moderate rain"},
+	65: {502, "heavy rain"},
+	66: {511, "light freezing rain"},
+	67: {511, "freezing rain"},
+	71: {600, "light snow"},
+	73: {601, "snow"},
+	75: {602, "heavy snow"},
+	77: {600, "snow grains"},
+	80: {520, "light rain showers"},
+	81: {521, "rain showers"},
+	82: {522, "heavy rain showers"},
+	85: {620, "light snow showers"},
+	86: {621, "snow showers"},
+	95: {211, "thunderstorm"},
+	96: {201, "thunderstorm with hail"},
+	99: {202, "thunderstorm with heavy hail"},
+}
+
+// wmoToOWM maps a WMO weather code, falling back to a plain cloudy sky for
+// codes Open-Meteo doesn't document
+func wmoToOWM(code int) wmoCondition {
+	if condition, ok := wmoConditions[code]; ok {
+		return condition
+	}
+	return wmoCondition{803, "cloudy"}
+}
+
+func (p *openMeteoProvider) Weather(ctx context.Context, httpClient HTTPClient) (models.WeatherData, error) {
+	temperatureUnit, windSpeedUnit := "celsius", "ms"
+	if p.settings.Units == "imperial" {
+		temperatureUnit, windSpeedUnit = "fahrenheit", "mph"
+	}
+
+	url := fmt.Sprintf("%s?latitude=%f&longitude=%f"+
+		"&current=temperature_2m,apparent_temperature,relative_humidity_2m,weather_code,cloud_cover,wind_speed_10m,wind_direction_10m,uv_index"+
+		"&hourly=temperature_2m,apparent_temperature,relative_humidity_2m,weather_code,wind_speed_10m"+
+		"&daily=weather_code,temperature_2m_max,temperature_2m_min,apparent_temperature_max"+
+		"&temperature_unit=%s&wind_speed_unit=%s&timeformat=unixtime&timezone=auto&forecast_days=%d",
+		p.baseURL,
+		p.settings.Lat,
+		p.settings.Lon,
+		temperatureUnit,
+		windSpeedUnit,
+		openMeteoForecastDays,
+	)
+
+	var forecast openMeteoResponse
+	if err := getJSON(ctx, httpClient, p.retryConfig, "Open-Meteo", url, nil, &forecast); err != nil {
+		return models.WeatherData{}, err
+	}
+	return forecast.weatherData()
+}
+
+func (r *openMeteoResponse) weatherData() (models.WeatherData, error) {
+	if len(r.Daily.Time) == 0 {
+		return models.WeatherData{}, fmt.Errorf("Open-Meteo response has no daily forecast")
+	}
+
+	condition := wmoToOWM(r.Current.WeatherCode)
+	weatherData := models.WeatherData{
+		Latitude:  r.Latitude,
+		Longitude: r.Longitude,
+		Temperature: models.TemperatureData{
+			Temp:      r.Current.Temperature,
+			TempMin:   float64Ptr(valueAt(r.Daily.TemperatureMin, 0)),
+			TempMax:   float64Ptr(valueAt(r.Daily.TemperatureMax, 0)),
+			FeelsLike: r.Current.ApparentTemperature,
+		},
+		Status:         conditionMain(condition.id),
+		DetailedStatus: condition.description,
+		Icon:           condition.id,
+		Humidity:       r.Current.RelativeHumidity,
+		WindSpeed:      r.Current.WindSpeed,
+		WindDir:        r.Current.WindDirection,
+		UVI:            r.Current.UVIndex,
+		Clouds:         r.Current.CloudCover,
+		Summary:        conditionMain(condition.id),
+	}
+
+	// Hourly values start at midnight; like One Call, start at the current hour
+	weatherData.HourlyForecast = []models.ForecastData{}
+	for i, timestamp := range r.Hourly.Time {
+		if timestamp+3600 <= r.Current.Time {
+			continue
+		}
+		if len(weatherData.HourlyForecast) == openMeteoForecastHours {
+			break
+		}
+		hourly := wmoToOWM(valueAt(r.Hourly.WeatherCode, i))
+		weatherData.HourlyForecast = append(weatherData.HourlyForecast, models.ForecastData{
+			Timestamp: timestamp,
+			Temperature: models.TemperatureData{
+				Temp:      valueAt(r.Hourly.Temperature, i),
+				FeelsLike: valueAt(r.Hourly.ApparentTemperature, i),
+			},
+			Status:    conditionMain(hourly.id),
+			Icon:      hourly.id,
+			Humidity:  int(valueAt(r.Hourly.RelativeHumidity, i)),
+			WindSpeed: valueAt(r.Hourly.WindSpeed, i),
+		})
+	}
+
+	weatherData.DailyForecast = make([]models.ForecastData, len(r.Daily.Time))
+	for i, timestamp := range r.Daily.Time {
+		daily := wmoToOWM(valueAt(r.Daily.WeatherCode, i))
+		weatherData.DailyForecast[i] = models.ForecastData{
+			Timestamp: timestamp,
+			Temperature: models.TemperatureData{
+				Temp:      valueAt(r.Daily.TemperatureMax, i),
+				TempMin:   float64Ptr(valueAt(r.Daily.TemperatureMin, i)),
+				TempMax:   float64Ptr(valueAt(r.Daily.TemperatureMax, i)),
+				FeelsLike: valueAt(r.Daily.ApparentTemperatureMax, i),
+			},
+			Status: conditionMain(daily.id),
+			Icon:   daily.id,
+		}
+	}
+
+	return weatherData, nil
+}
+
+// valueAt returns values[i], or the zero value when Open-Meteo sent a shorter
+// array
+func valueAt[T any](values []T, i int) T {
+	var zero T
+	if i >= len(values) {
+		return zero
+	}
+	return values[i]
+}
@@ -0,0 +1,155 @@
+package weatherservice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+)
+
+const testOpenMeteoResponse = `{
+  "latitude": 40.78,
+  "longitude": -73.97,
+  "current": {
+    "time": 1704902400,
+    "temperature_2m": 41.2,
+    "apparent_temperature": 35.6,
+    "relative_humidity_2m": 82,
+    "weather_code": 63,
+    "cloud_cover": 100,
+    "wind_speed_10m": 12.4,
+    "wind_direction_10m": 225,
+    "uv_index": 0.4
+  },
+  "hourly": {
+    "time": [1704895200, 1704898800, 1704902400, 1704906000],
+    "temperature_2m": [39.0, 40.1, 41.2, 42.0],
+    "apparent_temperature": [33.0, 34.2, 35.6, 36.1],
+    "relative_humidity_2m": [90, 86, 82, 80],
+    "weather_code": [3, 61, 63, 95],
+    "wind_speed_10m": [8.0, 10.2, 12.4, 14.9]
+  },
+  "daily": {
+    "time": [1704862800, 1704949200],
+    "weather_code": [63, 0],
+    "temperature_2m_max": [44.5, 38.0],
+    "temperature_2m_min": [36.1, 27.3],
+    "apparent_temperature_max": [39.9, 31.0]
+  }
+}`
+
+func TestOpenMeteoProvider(t *testing.T) {
+	var query map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = map[string]string{}
+		for name := range r.URL.Query() {
+			query[name] = r.URL.Query().Get(name)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testOpenMeteoResponse))
+	}))
+	defer ts.Close()
+
+	provider := &openMeteoProvider{
+		settings:    models.WeatherSettings{Lat: 40.785091, Lon: -73.968285, Units: "imperial"},
+		retryConfig: retry.DefaultConfig(),
+		baseURL:     ts.URL,
+	}
+	weatherData, err := provider.Weather(context.Background(), ts.Client())
+	if err != nil {
+		t.Fatalf("Failed to fetch weather: %v", err)
+	}
+
+	if query["temperature_unit"] != "fahrenheit" || query["wind_speed_unit"] != "mph" || query["timeformat"] != "unixtime" {
+		t.Errorf("Expected imperial units in unix time, got %v", query)
+	}
+
+	if weatherData.Temperature.Temp != 41.2 || weatherData.Temperature.FeelsLike != 35.6 {
+		t.Errorf("Expected current temperature 41.2 feeling like 35.6, got %+v", weatherData.Temperature)
+	}
+	if *weatherData.Temperature.TempMin != 36.1 || *weatherData.Temperature.TempMax != 44.5 {
+		t.Errorf("Expected today's range 36.1..44.5, got %+v", weatherData.Temperature)
+	}
+	if weatherData.Icon != 501 || weatherData.Status != "Rain" || weatherData.DetailedStatus != "moderate rain" {
+		t.Errorf("Expected moderate rain (501), got %d %s %q", weatherData.Icon, weatherData.Status, weatherData.DetailedStatus)
+	}
+	if weatherData.Humidity != 82 || weatherData.WindSpeed != 12.4 || weatherData.WindDir != 225 || weatherData.UVI != 0.4 || weatherData.Clouds != 100 {
+		t.Errorf("Expected the current conditions, got %+v", weatherData)
+	}
+
+	// Hours before the current one are dropped
+	if len(weatherData.HourlyForecast) != 2 {
+		t.Fatalf("Expected 2 hourly forecasts from the current hour, got %d", len(weatherData.HourlyForecast))
+	}
+	if next := weatherData.HourlyForecast[1]; next.Timestamp != 1704906000 || next.Icon != 211 || next.Status != "Thunderstorm" || next.Humidity != 80 {
+		t.Errorf("Expected a thunderstorm at 1704906000, got %+v", next)
+	}
+
+	if len(weatherData.DailyForecast) != 2 {
+		t.Fatalf("Expected 2 daily forecasts, got %d", len(weatherData.DailyForecast))
+	}
+	if tomorrow := weatherData.DailyForecast[1]; tomorrow.Icon != 800 || tomorrow.Status != "Clear" || *tomorrow.Temperature.TempMin != 27.3 || *tomorrow.Temperature.TempMax != 38.0 {
+		t.Errorf("Expected a clear day from 27.3 to 38, got %+v", tomorrow)
+	}
+}
+
+func TestOpenMeteoProviderMetricUnits(t *testing.T) {
+	var query string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(testOpenMeteoResponse))
+	}))
+	defer ts.Close()
+
+	provider := &openMeteoProvider{
+		settings:    models.WeatherSettings{Units: "metric"},
+		retryConfig: retry.DefaultConfig(),
+		baseURL:     ts.URL,
+	}
+	if _, err := provider.Weather(context.Background(), ts.Client()); err != nil {
+		t.Fatalf("Failed to fetch weather: %v", err)
+	}
+	if !strings.Contains(query, "temperature_unit=celsius") || !strings.Contains(query, "wind_speed_unit=ms") {
+		t.Errorf("Expected celsius and m/s, got %s", query)
+	}
+}
+
+func TestOpenMeteoProviderWithoutDailyForecast(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"current": {"temperature_2m": 20}}`))
+	}))
+	defer ts.Close()
+
+	provider := &openMeteoProvider{retryConfig: retry.DefaultConfig(), baseURL: ts.URL}
+	if _, err := provider.Weather(context.Background(), ts.Client()); err == nil {
+		t.Error("Expected an error for a response without daily forecast")
+	}
+}
+
+func TestWMOToOWM(t *testing.T) {
+	testCases := []struct {
+		code int
+		id   int
+		main string
+	}{
+		{0, 800, "Clear"},
+		{2, 802, "Clouds"},
+		{45, 741, "Fog"},
+		{55, 302, "Drizzle"},
+		{66, 511, "Rain"},
+		{75, 602, "Snow"},
+		{99, 202, "Thunderstorm"},
+		{42, 803, "Clouds"},
+	}
+
+	for _, tc := range testCases {
+		condition := wmoToOWM(tc.code)
+		if condition.id != tc.id || conditionMain(condition.id) != tc.main {
+			t.Errorf("WMO code %d: expected %d %s, got %d %s", tc.code, tc.id, tc.main, condition.id, conditionMain(condition.id))
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package weatherservice
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+)
+
+// OpenWeatherMap One Call 3.0 endpoint
+const owmBaseURL = "https://api.openweathermap.org/data/3.0/onecall"
+
+// owmProvider reads the OpenWeatherMap One Call API, which needs an API key
+// with billing information
+type owmProvider struct {
+	settings    models.WeatherSettings
+	retryConfig retry.Config
+	baseURL     string
+}
+
+func (p *owmProvider) Weather(ctx context.Context, httpClient HTTPClient) (models.WeatherData, error) {
+	owmData, err := p.fetchWeatherData(ctx, httpClient)
+	if err != nil {
+		return models.WeatherData{}, err
+	}
+
+	problems, err := validateOWMData(owmData)
+	if err != nil {
+		return models.WeatherData{}, err
+	}
+
+	weatherData := p.mapToWeatherData(owmData)
+	for _, problem := range problems {
+		weatherData.Warnings = append(weatherData.Warnings, problem.Error())
+	}
+	return weatherData, nil
+}
+
+func (p *owmProvider) fetchWeatherData(ctx context.Context, httpClient HTTPClient) (*models.OWMWeatherData, error) {
+	url := fmt.Sprintf("%s?lat=%f&lon=%f&units=%s&appid=%s",
+		p.baseURL,
+		p.settings.Lat,
+		p.settings.Lon,
+		p.settings.Units,
+		p.settings.APIKey,
+	)
+
+	var owmData models.OWMWeatherData
+	if err := getJSON(ctx, httpClient, p.retryConfig, "weather API", url, nil, &owmData); err != nil {
+		return nil, err
+	}
+	return &owmData, nil
+}
+
+// mapToWeatherData maps a One Call response, degrading gracefully where
+// validateOWMData found problems: without conditions the status is unknown,
+// and without a daily forecast today's range and sun and moon are left out
+func (p *owmProvider) mapToWeatherData(owmData *models.OWMWeatherData) models.WeatherData {
+	current := owmCondition(owmData.Current.Weather)
+	weatherData := models.WeatherData{
+		Latitude:  owmData.Lat,
+		Longitude: owmData.Lon,
+		Temperature: models.TemperatureData{
+			Temp:      owmData.Current.Temp,
+			FeelsLike: owmData.Current.FeelsLike,
+		},
+		Status:         current.Main,
+		DetailedStatus: current.Description,
+		Icon:           current.ID,
+		Humidity:       float64(owmData.Current.Humidity),
+		WindSpeed:      owmData.Current.WindSpeed,
+		WindDir:        owmData.Current.WindDeg,
+		UVI:            owmData.Current.UVI,
+		Clouds:         owmData.Current.Clouds,
+		Summary:        current.Main,
+		Rain:           owmData.Current.Rain.OneHour,
+		Snow:           owmData.Current.Snow.OneHour,
+	}
+
+	if len(owmData.Daily) > 0 {
+		today := owmData.Daily[0]
+		weatherData.Temperature.TempMin = float64Ptr(today.Temp.Min)
+		weatherData.Temperature.TempMax = float64Ptr(today.Temp.Max)
+		weatherData.Pop = today.Pop
+
+		// The current sunrise and sunset are today's, like those of the first day
+		today.Sunrise, today.Sunset = owmData.Current.Sunrise, owmData.Current.Sunset
+		weatherData.Astronomy = dailyAstronomy(today)
+	}
+
+	weatherData.HourlyForecast = mapHourlyForecast(owmData.Hourly)
+	weatherData.DailyForecast = mapDailyForecast(owmData.Daily)
+	weatherData.Alerts = mapAlerts(owmData.Alerts)
+	weatherData.Nowcast = mapNowcast(owmData.Minutely)
+
+	return weatherData
+}
+
+// mapNowcast keeps the minutely series; the summary is computed when the
+// weather is served
+func mapNowcast(minutely []models.OWMMinutely) *models.Nowcast {
+	if len(minutely) == 0 {
+		return nil
+	}
+
+	nowcast := &models.Nowcast{Minutes: make([]models.NowcastMinute, len(minutely))}
+	for i, minute := range minutely {
+		nowcast.Minutes[i] = models.NowcastMinute{Timestamp: minute.Timestamp, Precipitation: minute.Precipitation}
+	}
+	return nowcast
+}
+
+func mapAlerts(owmAlerts []models.OWMAlert) []models.WeatherAlert {
+	var alerts []models.WeatherAlert
+	for _, alert := range owmAlerts {
+		alerts = append(alerts, models.WeatherAlert{
+			Sender:      alert.SenderName,
+			Event:       alert.Event,
+			Start:       alert.Start,
+			End:         alert.End,
+			Description: alert.Description,
+			Severity:    alertSeverity(alert.Event),
+		})
+	}
+	return alerts
+}
+
+// mapHourlyForecast maps the hourly forecast, leaving out hours without a
+// timestamp
+func mapHourlyForecast(hourlyData []models.OWMHourlyForecast) []models.ForecastData {
+	forecast := make([]models.ForecastData, 0, len(hourlyData))
+	for _, hourly := range hourlyData {
+		if hourly.Timestamp == 0 {
+			continue
+		}
+		condition := owmCondition(hourly.Weather)
+		forecast = append(forecast, models.ForecastData{
+			Timestamp: hourly.Timestamp,
+			Temperature: models.TemperatureData{
+				Temp:      hourly.Temp,
+				FeelsLike: hourly.FeelsLike,
+			},
+			Status:    condition.Main,
+			Icon:      condition.ID,
+			Humidity:  hourly.Humidity,
+			WindSpeed: hourly.WindSpeed,
+			Pop:       hourly.Pop,
+			Rain:      hourly.Rain.OneHour,
+			Snow:      hourly.Snow.OneHour,
+			UVI:       hourly.UVI,
+		})
+	}
+	return forecast
+}
+
+// mapDailyForecast maps the daily forecast, leaving out days without a
+// timestamp
+func mapDailyForecast(dailyData []models.OWMDailyForecast) []models.ForecastData {
+	forecast := make([]models.ForecastData, 0, len(dailyData))
+	for _, daily := range dailyData {
+		if daily.Timestamp == 0 {
+			continue
+		}
+		condition := owmCondition(daily.Weather)
+		forecast = append(forecast, models.ForecastData{
+			Timestamp: daily.Timestamp,
+			Temperature: models.TemperatureData{
+				Temp:      daily.Temp.Day,
+				TempMin:   float64Ptr(daily.Temp.Min),
+				TempMax:   float64Ptr(daily.Temp.Max),
+				FeelsLike: daily.FeelsLike.Day,
+			},
+			Status:    condition.Main,
+			Icon:      condition.ID,
+			Humidity:  daily.Humidity,
+			WindSpeed: daily.WindSpeed,
+			Pop:       daily.Pop,
+			Rain:      daily.Rain,
+			Snow:      daily.Snow,
+			UVI:       daily.UVI,
+			Summary:   daily.Summary,
+			Astronomy: dailyAstronomy(daily),
+		})
+	}
+	return forecast
+}
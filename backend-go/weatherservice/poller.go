@@ -0,0 +1,42 @@
+package weatherservice
+
+import (
+	"context"
+	"time"
+)
+
+// Start refreshes the weather in the background, immediately and then every
+// refresh interval, until ctx is done. Handlers only serve the resulting
+// snapshot, so page loads never wait on the weather provider.
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		s.refreshWithTimeout(ctx)
+
+		ticker := time.NewTicker(s.refreshInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.refreshWithTimeout(ctx)
+			}
+		}
+	}()
+}
+
+func (s *Service) refreshWithTimeout(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	// Errors are logged by refresh and reflected in the snapshot
+	s.refresh(ctx)
+}
+
+func (s *Service) refreshInterval() time.Duration {
+	if minutes := s.weatherConfig.Weather.RefreshIntervalMinutes; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultRefreshInterval
+}
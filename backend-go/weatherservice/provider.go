@@ -0,0 +1,114 @@
+package weatherservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+)
+
+// Weather providers accepted in WeatherSettings.Provider
+const (
+	providerOpenWeatherMap = "openweathermap"
+	providerOpenMeteo      = "open-meteo"
+	providerNWS            = "nws"
+)
+
+// Provider fetches the weather from one weather service and maps it into
+// models.WeatherData. Icons and statuses use OpenWeatherMap condition codes,
+// which the frontend draws with the weather-icons font.
+type Provider interface {
+	Weather(ctx context.Context, httpClient HTTPClient) (models.WeatherData, error)
+}
+
+// newProvider returns the provider selected in the weather settings,
+// OpenWeatherMap by default
+func newProvider(settings models.WeatherSettings, retryConfig retry.Config) (Provider, error) {
+	switch settings.Provider {
+	case "", providerOpenWeatherMap:
+		return &owmProvider{settings: settings, retryConfig: retryConfig, baseURL: owmBaseURL}, nil
+	case providerOpenMeteo:
+		return &openMeteoProvider{settings: settings, retryConfig: retryConfig, baseURL: openMeteoBaseURL}, nil
+	case providerNWS:
+		return &nwsProvider{settings: settings, retryConfig: retryConfig, baseURL: nwsBaseURL}, nil
+	default:
+		return nil, fmt.Errorf("unknown weather provider %q", settings.Provider)
+	}
+}
+
+// getJSON fetches url, retrying transient failures, and decodes the JSON
+// response into v. api names the weather service in errors.
+func getJSON(ctx context.Context, httpClient HTTPClient, cfg retry.Config, api, url string, header http.Header, v interface{}) error {
+	return retry.Do(ctx, cfg, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %v", err)
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to fetch weather from %s: %v", api, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			// Check if this is a transient status code
+			if retry.IsTransientStatusCode(resp.StatusCode) {
+				return fmt.Errorf("%s returned transient error: status code %d", api, resp.StatusCode)
+			}
+			// Non-transient errors (like 401 unauthorized) won't be retried
+			return fmt.Errorf("%s error: status code %d", api, resp.StatusCode)
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return fmt.Errorf("failed to parse %s response: %v", api, err)
+		}
+		return nil
+	})
+}
+
+// conditionMain returns the OpenWeatherMap condition group ("Rain", "Clouds",
+// ...) of a condition code
+func conditionMain(id int) string {
+	switch {
+	case id >= 200 && id < 300:
+		return "Thunderstorm"
+	case id >= 300 && id < 400:
+		return "Drizzle"
+	case id >= 500 && id < 600:
+		return "Rain"
+	case id >= 600 && id < 700:
+		return "Snow"
+	case id == 800:
+		return "Clear"
+	case id > 800:
+		return "Clouds"
+	}
+
+	switch id {
+	case 711:
+		return "Smoke"
+	case 721:
+		return "Haze"
+	case 731, 761:
+		return "Dust"
+	case 741:
+		return "Fog"
+	case 771:
+		return "Squall"
+	case 781:
+		return "Tornado"
+	}
+	return "Mist"
+}
+
+// float64Ptr returns a pointer to a copy of value
+func float64Ptr(value float64) *float64 {
+	return &value
+}
@@ -0,0 +1,67 @@
+package weatherservice
+
+import (
+	"fmt"
+
+	"github.com/coreyk/piinky/backend-go/models"
+)
+
+// Status of the weather when the provider sent no condition
+const conditionUnknown = "unknown"
+
+// OWMPayloadError describes a value missing from an OpenWeatherMap response
+type OWMPayloadError struct {
+	Field   string // Path of the value, e.g. "hourly[3].weather"
+	Problem string // e.g. "is empty"
+}
+
+func (e *OWMPayloadError) Error() string {
+	return fmt.Sprintf("OpenWeatherMap response %s %s", e.Field, e.Problem)
+}
+
+// validateOWMData checks a decoded One Call response. It returns an error
+// when the response has no current weather at all, as when OpenWeatherMap
+// answers with an error message, and otherwise the problems mapToWeatherData
+// works around, which are served as the weather's warnings.
+func validateOWMData(owmData *models.OWMWeatherData) ([]*OWMPayloadError, error) {
+	if owmData.Current.Timestamp == 0 && len(owmData.Current.Weather) == 0 {
+		return nil, &OWMPayloadError{Field: "current", Problem: "is missing"}
+	}
+
+	var problems []*OWMPayloadError
+	if len(owmData.Current.Weather) == 0 {
+		problems = append(problems, &OWMPayloadError{Field: "current.weather", Problem: "is empty"})
+	}
+	if len(owmData.Daily) == 0 {
+		problems = append(problems, &OWMPayloadError{Field: "daily", Problem: "is empty"})
+	}
+
+	for i, hourly := range owmData.Hourly {
+		problems = append(problems, forecastProblems(fmt.Sprintf("hourly[%d]", i), hourly.Timestamp, hourly.Weather)...)
+	}
+	for i, daily := range owmData.Daily {
+		problems = append(problems, forecastProblems(fmt.Sprintf("daily[%d]", i), daily.Timestamp, daily.Weather)...)
+	}
+
+	return problems, nil
+}
+
+// forecastProblems checks an hourly or daily forecast entry
+func forecastProblems(field string, timestamp int64, conditions []models.OWMCondition) []*OWMPayloadError {
+	if timestamp == 0 {
+		return []*OWMPayloadError{{Field: field + ".dt", Problem: "is missing"}}
+	}
+	if len(conditions) == 0 {
+		return []*OWMPayloadError{{Field: field + ".weather", Problem: "is empty"}}
+	}
+	return nil
+}
+
+// owmCondition returns the primary condition, or an unknown one when
+// OpenWeatherMap sent none
+func owmCondition(conditions []models.OWMCondition) models.OWMCondition {
+	if len(conditions) == 0 {
+		return models.OWMCondition{Main: conditionUnknown, Description: conditionUnknown}
+	}
+	return conditions[0]
+}
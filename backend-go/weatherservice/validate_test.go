@@ -0,0 +1,166 @@
+package weatherservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
+)
+
+// owmWeather serves payload as the One Call response and maps it
+func owmWeather(t *testing.T, payload string) (models.WeatherData, error) {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(payload))
+	}))
+	defer ts.Close()
+
+	provider := &owmProvider{retryConfig: retry.DefaultConfig(), baseURL: ts.URL}
+	return provider.Weather(context.Background(), ts.Client())
+}
+
+func TestValidateOWMData(t *testing.T) {
+	testCases := []struct {
+		name     string
+		payload  string
+		problems []string
+	}{
+		{
+			name:    "complete",
+			payload: `{"current": {"dt": 1, "weather": [{"id": 800}]}, "hourly": [{"dt": 1, "weather": [{"id": 800}]}], "daily": [{"dt": 1, "weather": [{"id": 800}]}]}`,
+		},
+		{
+			name:     "no current condition",
+			payload:  `{"current": {"dt": 1, "weather": []}, "daily": [{"dt": 1, "weather": [{"id": 800}]}]}`,
+			problems: []string{"OpenWeatherMap response current.weather is empty"},
+		},
+		{
+			name:     "no daily forecast",
+			payload:  `{"current": {"dt": 1, "weather": [{"id": 800}]}}`,
+			problems: []string{"OpenWeatherMap response daily is empty"},
+		},
+		{
+			name:     "hour without condition",
+			payload:  `{"current": {"dt": 1, "weather": [{"id": 800}]}, "hourly": [{"dt": 1, "weather": [{"id": 800}]}, {"dt": 2}], "daily": [{"dt": 1, "weather": [{"id": 800}]}]}`,
+			problems: []string{"OpenWeatherMap response hourly[1].weather is empty"},
+		},
+		{
+			name:     "hour without timestamp",
+			payload:  `{"current": {"dt": 1, "weather": [{"id": 800}]}, "hourly": [{"weather": [{"id": 800}]}], "daily": [{"dt": 1, "weather": [{"id": 800}]}]}`,
+			problems: []string{"OpenWeatherMap response hourly[0].dt is missing"},
+		},
+		{
+			name:     "day without condition",
+			payload:  `{"current": {"dt": 1, "weather": [{"id": 800}]}, "daily": [{"dt": 1, "weather": null}]}`,
+			problems: []string{"OpenWeatherMap response daily[0].weather is empty"},
+		},
+	}
+
+	for _, tc := range testCases {
+		var owmData models.OWMWeatherData
+		if err := json.Unmarshal([]byte(tc.payload), &owmData); err != nil {
+			t.Fatalf("%s: failed to decode payload: %v", tc.name, err)
+		}
+
+		problems, err := validateOWMData(&owmData)
+		if err != nil {
+			t.Errorf("%s: expected a usable response, got %v", tc.name, err)
+			continue
+		}
+		if len(problems) != len(tc.problems) {
+			t.Errorf("%s: expected problems %v, got %v", tc.name, tc.problems, problems)
+			continue
+		}
+		for i, problem := range problems {
+			if problem.Error() != tc.problems[i] {
+				t.Errorf("%s: expected %q, got %q", tc.name, tc.problems[i], problem.Error())
+			}
+		}
+	}
+}
+
+func TestOWMWeatherRejectsMissingCurrent(t *testing.T) {
+	for _, payload := range []string{`{}`, `{"cod": 429, "message": "Your account is temporary blocked"}`} {
+		_, err := owmWeather(t, payload)
+
+		var payloadErr *OWMPayloadError
+		if !errors.As(err, &payloadErr) || payloadErr.Field != "current" {
+			t.Errorf("%s: expected a missing current error, got %v", payload, err)
+		}
+	}
+}
+
+func TestOWMWeatherWithoutConditions(t *testing.T) {
+	weatherData, err := owmWeather(t, `{
+		"current": {"dt": 1704950000, "temp": 4, "weather": []},
+		"hourly": [{"dt": 1704952800, "temp": 5}],
+		"daily": [{"dt": 1704970800, "temp": {"min": 1, "max": 6}}]
+	}`)
+	if err != nil {
+		t.Fatalf("Expected the weather to degrade, got %v", err)
+	}
+
+	if weatherData.Status != conditionUnknown || weatherData.DetailedStatus != conditionUnknown || weatherData.Icon != 0 {
+		t.Errorf("Expected an unknown current condition, got %s/%s/%d", weatherData.Status, weatherData.DetailedStatus, weatherData.Icon)
+	}
+	if weatherData.HourlyForecast[0].Status != conditionUnknown || weatherData.DailyForecast[0].Status != conditionUnknown {
+		t.Errorf("Expected unknown forecast conditions, got %+v and %+v", weatherData.HourlyForecast[0], weatherData.DailyForecast[0])
+	}
+	if weatherData.Temperature.TempMax == nil || *weatherData.Temperature.TempMax != 6 {
+		t.Errorf("Expected today's maximum, got %v", weatherData.Temperature.TempMax)
+	}
+
+	expected := []string{
+		"OpenWeatherMap response current.weather is empty",
+		"OpenWeatherMap response hourly[0].weather is empty",
+		"OpenWeatherMap response daily[0].weather is empty",
+	}
+	if got := strings.Join(weatherData.Warnings, "; "); got != strings.Join(expected, "; ") {
+		t.Errorf("Expected warnings %q, got %q", expected, weatherData.Warnings)
+	}
+}
+
+func TestOWMWeatherWithoutDailyForecast(t *testing.T) {
+	weatherData, err := owmWeather(t, `{
+		"current": {"dt": 1704950000, "temp": 4, "weather": [{"id": 500, "main": "Rain"}]},
+		"hourly": [{"weather": [{"id": 500}]}, {"dt": 1704952800, "temp": 5, "weather": [{"id": 500, "main": "Rain"}]}]
+	}`)
+	if err != nil {
+		t.Fatalf("Expected the weather to degrade, got %v", err)
+	}
+
+	if weatherData.Status != "Rain" || weatherData.Temperature.Temp != 4 {
+		t.Errorf("Expected the current weather, got %+v", weatherData)
+	}
+	if weatherData.Temperature.TempMin != nil || weatherData.Temperature.TempMax != nil || weatherData.Astronomy != nil {
+		t.Errorf("Expected no range or astronomy without a daily forecast, got %+v", weatherData)
+	}
+	if len(weatherData.DailyForecast) != 0 {
+		t.Errorf("Expected no daily forecast, got %d days", len(weatherData.DailyForecast))
+	}
+	if len(weatherData.HourlyForecast) != 1 || weatherData.HourlyForecast[0].Timestamp != 1704952800 {
+		t.Errorf("Expected the hour without a timestamp to be dropped, got %+v", weatherData.HourlyForecast)
+	}
+
+	expected := []string{
+		"OpenWeatherMap response daily is empty",
+		"OpenWeatherMap response hourly[0].dt is missing",
+	}
+	if got := strings.Join(weatherData.Warnings, "; "); got != strings.Join(expected, "; ") {
+		t.Errorf("Expected warnings %q, got %q", expected, weatherData.Warnings)
+	}
+
+	body, err := json.Marshal(weatherData.Temperature)
+	if err != nil {
+		t.Fatalf("Failed to encode temperature: %v", err)
+	}
+	if string(body) != `{"temp":4,"feels_like":0}` {
+		t.Errorf("Expected min and max to be omitted, got %s", body)
+	}
+}
@@ -3,14 +3,17 @@ package weatherservice
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/coreyk/piinky/backend-go/models"
 	"github.com/coreyk/piinky/backend-go/retry"
+	"github.com/coreyk/piinky/backend-go/snapshot"
 )
 
 // HTTPClient interface
@@ -18,16 +21,29 @@ type HTTPClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+// Default interval between background refreshes
+const defaultRefreshInterval = 15 * time.Minute
+
 // HTTP client configuration
 const (
 	httpTimeout     = 30 * time.Second
 	requestTimeout  = 45 * time.Second // Overall timeout including retries
 )
 
+// Name of the last-known-good weather snapshot
+const weatherSnapshotName = "weather"
+
 type Service struct {
 	weatherConfig models.WeatherConfig
+	provider      Provider
 	httpClient    HTTPClient
 	retryConfig   retry.Config
+	snapshots     *snapshot.Store
+	now           func() time.Time // For testing purposes
+
+	mu      sync.Mutex
+	current *models.WeatherData // Last fetched weather, served by the handler
+	stale   bool                // Whether the last refresh failed
 }
 
 func NewService() (*Service, error) {
@@ -52,10 +68,24 @@ func NewService() (*Service, error) {
 		Timeout: httpTimeout,
 	}
 
+	snapshots, err := snapshot.NewStoreFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	retryConfig := retry.DefaultConfig()
+	provider, err := newProvider(weatherConfig.Weather, retryConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Service{
 		weatherConfig: weatherConfig,
+		provider:      provider,
 		httpClient:    httpClient,
-		retryConfig:   retry.DefaultConfig(),
+		retryConfig:   retryConfig,
+		snapshots:     snapshots,
+		now:           time.Now,
 	}, nil
 }
 
@@ -70,18 +100,22 @@ func (s *Service) HandleGetWeather(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
-	defer cancel()
-
-	owmData, err := s.fetchWeatherDataWithRetry(ctx)
-	if err != nil {
-		log.Printf("Weather API error: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	weatherResponse, ok := s.currentWeather()
+	if !ok {
+		// Nothing has been fetched yet, e.g. before the poller's first run
+		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+		defer cancel()
+
+		if err := s.refresh(ctx); err != nil {
+			if weatherResponse, ok = s.currentWeather(); !ok {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		} else {
+			weatherResponse, _ = s.currentWeather()
+		}
 	}
 
-	weatherResponse := s.mapToWeatherData(owmData)
-
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(weatherResponse); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to encode response: %v", err), http.StatusInternalServerError)
@@ -89,108 +123,86 @@ func (s *Service) HandleGetWeather(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Service) fetchWeatherDataWithRetry(ctx context.Context) (*models.OWMWeatherData, error) {
-	return retry.DoWithResult(ctx, s.retryConfig, func() (*models.OWMWeatherData, error) {
-		return s.fetchWeatherData(ctx)
-	})
-}
+// currentWeather returns the in-memory snapshot, flagged as stale with its
+// age when the last refresh failed
+func (s *Service) currentWeather() (models.WeatherData, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-func (s *Service) fetchWeatherData(ctx context.Context) (*models.OWMWeatherData, error) {
-	url := fmt.Sprintf("https://api.openweathermap.org/data/3.0/onecall?lat=%f&lon=%f&exclude=minutely,alerts&units=%s&appid=%s",
-		s.weatherConfig.Weather.Lat,
-		s.weatherConfig.Weather.Lon,
-		s.weatherConfig.Weather.Units,
-		s.weatherConfig.Weather.APIKey,
-	)
+	if s.current == nil {
+		return models.WeatherData{}, false
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+	now := s.now().Unix()
+	weatherData := *s.current
+	// The snapshot may be served long after it was fetched
+	weatherData.Alerts = currentAlerts(weatherData.Alerts, now)
+	weatherData.Nowcast = nowcastAt(weatherData.Nowcast, weatherData.Status, now)
+	if s.stale {
+		weatherData.Stale = true
+		weatherData.StaleAgeSeconds = now - weatherData.FetchedAt
 	}
+	return weatherData, true
+}
 
-	resp, err := s.httpClient.Do(req)
+// refresh fetches the weather and replaces the in-memory snapshot. On failure
+// the previous snapshot is kept, falling back to the one saved on disk.
+func (s *Service) refresh(ctx context.Context) error {
+	weatherData, err := s.provider.Weather(ctx, s.httpClient)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch current weather: %v", err)
-	}
-	defer resp.Body.Close()
+		log.Printf("Weather API error: %v", err)
 
-	if resp.StatusCode != http.StatusOK {
-		// Check if this is a transient status code
-		if retry.IsTransientStatusCode(resp.StatusCode) {
-			return nil, fmt.Errorf("weather API returned transient error: status code %d", resp.StatusCode)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.current == nil {
+			if stale, ok := s.loadStaleWeather(); ok {
+				s.current = &stale
+			}
 		}
-		// Non-transient errors (like 401 unauthorized) won't be retried
-		return nil, fmt.Errorf("weather API error: status code %d", resp.StatusCode)
+		s.stale = true
+		return err
 	}
 
-	var owmData models.OWMWeatherData
-	if err := json.NewDecoder(resp.Body).Decode(&owmData); err != nil {
-		return nil, fmt.Errorf("failed to parse weather data: %v", err)
+	for _, warning := range weatherData.Warnings {
+		log.Printf("Weather warning: %s", warning)
 	}
 
-	return &owmData, nil
-}
+	weatherData.Location = s.weatherConfig.Weather.Location
+	weatherData.Alerts = dedupeAlerts(weatherData.Alerts)
+	weatherData.FetchedAt = s.now().Unix()
+	s.saveSnapshot(weatherData)
 
-func (s *Service) mapToWeatherData(owmData *models.OWMWeatherData) models.WeatherData {
-	weatherData := models.WeatherData{
-		Latitude:  owmData.Lat,
-		Longitude: owmData.Lon,
-		Temperature: models.TemperatureData{
-			Temp:      owmData.Current.Temp,
-			TempMin:   owmData.Daily[0].Temp.Min,
-			TempMax:   owmData.Daily[0].Temp.Max,
-			FeelsLike: owmData.Current.FeelsLike,
-		},
-		Status:         owmData.Current.Weather[0].Main,
-		DetailedStatus: owmData.Current.Weather[0].Description,
-		Icon:           owmData.Current.Weather[0].ID,
-		Humidity:       float64(owmData.Current.Humidity),
-		WindSpeed:      owmData.Current.WindSpeed,
-		WindDir:        owmData.Current.WindDeg,
-		UVI:            owmData.Current.UVI,
-		Clouds:         owmData.Current.Clouds,
-		Summary:        owmData.Current.Weather[0].Main,
-		Location:       s.weatherConfig.Weather.Location,
-	}
-
-	weatherData.HourlyForecast = mapHourlyForecast(owmData.Hourly)
-	weatherData.DailyForecast = mapDailyForecast(owmData.Daily)
-
-	return weatherData
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = &weatherData
+	s.stale = false
+	return nil
 }
 
-func mapHourlyForecast(hourlyData []models.OWMHourlyForecast) []models.ForecastData {
-	forecast := make([]models.ForecastData, len(hourlyData))
-	for i, hourly := range hourlyData {
-		forecast[i] = models.ForecastData{
-			Timestamp: hourly.Timestamp,
-			Temperature: models.TemperatureData{
-				Temp:      hourly.Temp,
-				FeelsLike: hourly.FeelsLike,
-			},
-			Status:    hourly.Weather[0].Main,
-			Icon:      hourly.Weather[0].ID,
-			Humidity:  hourly.Humidity,
-			WindSpeed: hourly.WindSpeed,
-		}
+func (s *Service) saveSnapshot(weatherData models.WeatherData) {
+	if s.snapshots == nil {
+		return
+	}
+	if err := s.snapshots.Save(weatherSnapshotName, weatherData, time.Unix(weatherData.FetchedAt, 0)); err != nil {
+		log.Printf("Failed to save weather snapshot: %v", err)
 	}
-	return forecast
 }
 
-func mapDailyForecast(dailyData []models.OWMDailyForecast) []models.ForecastData {
-	forecast := make([]models.ForecastData, len(dailyData))
-	for i, daily := range dailyData {
-		forecast[i] = models.ForecastData{
-			Timestamp: daily.Timestamp,
-			Temperature: models.TemperatureData{
-				Temp:      daily.Temp.Day,
-				TempMin:   daily.Temp.Min,
-				TempMax:   daily.Temp.Max,
-				FeelsLike: daily.FeelsLike.Day,
-			},
-			Status: daily.Weather[0].Main,
-			Icon:   daily.Weather[0].ID,
+// loadStaleWeather returns the last-known-good weather saved on disk
+func (s *Service) loadStaleWeather() (models.WeatherData, bool) {
+	var weatherData models.WeatherData
+	if s.snapshots == nil {
+		return weatherData, false
+	}
+
+	fetchedAt, err := s.snapshots.Load(weatherSnapshotName, &weatherData)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to load weather snapshot: %v", err)
 		}
+		return weatherData, false
 	}
-	return forecast
+
+	weatherData.FetchedAt = fetchedAt.Unix()
+	return weatherData, true
 }
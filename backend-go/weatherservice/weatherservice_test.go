@@ -2,7 +2,9 @@ package weatherservice
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/coreyk/piinky/backend-go/models"
+	"github.com/coreyk/piinky/backend-go/retry"
 )
 
 type mockHTTPClient struct {
@@ -30,13 +33,7 @@ func setupTestConfig(t *testing.T) (string, func()) {
 
 	// Create a temporary weather config file
 	tempConfig := models.WeatherConfig{
-		Weather: struct {
-			APIKey   string  `json:"api_key"`
-			Lat      float64 `json:"lat"`
-			Lon      float64 `json:"lon"`
-			Location string  `json:"location"`
-			Units    string  `json:"units"`
-		}{
+		Weather: models.WeatherSettings{
 			APIKey:   "test-api-key",
 			Lat:      37.7749,
 			Lon:      -122.4194,
@@ -60,11 +57,13 @@ func setupTestConfig(t *testing.T) (string, func()) {
 
 	// Set environment variables
 	os.Setenv("OWM_CONFIG_FILE", configPath)
+	os.Setenv("SNAPSHOT_DIR", filepath.Join(tmpDir, "snapshots"))
 
 	// Return cleanup function
 	cleanup := func() {
 		os.RemoveAll(tmpDir)
 		os.Unsetenv("OWM_CONFIG_FILE")
+		os.Unsetenv("SNAPSHOT_DIR")
 	}
 
 	return tmpDir, cleanup
@@ -101,6 +100,8 @@ func TestMapToWeatherData(t *testing.T) {
 			WindDeg    float64               `json:"wind_deg"`
 			WindGust   float64               `json:"wind_gust"`
 			Weather    []models.OWMCondition `json:"weather"`
+			Rain       models.OWMVolume      `json:"rain"`
+			Snow       models.OWMVolume      `json:"snow"`
 		}{
 			Timestamp: 1641456000,
 			Temp:      20.5,
@@ -159,8 +160,8 @@ func TestMapToWeatherData(t *testing.T) {
 		},
 	}
 
-	svc := &Service{}
-	weatherData := svc.mapToWeatherData(owmData)
+	provider := &owmProvider{}
+	weatherData := provider.mapToWeatherData(owmData)
 
 	// Test mapped data
 	if weatherData.Latitude != owmData.Lat {
@@ -256,11 +257,11 @@ func TestMapDailyForecast(t *testing.T) {
 	if forecast[0].Temperature.Temp != dailyData[0].Temp.Day {
 		t.Errorf("Expected temperature %f, got %f", dailyData[0].Temp.Day, forecast[0].Temperature.Temp)
 	}
-	if forecast[0].Temperature.TempMin != dailyData[0].Temp.Min {
-		t.Errorf("Expected min temperature %f, got %f", dailyData[0].Temp.Min, forecast[0].Temperature.TempMin)
+	if *forecast[0].Temperature.TempMin != dailyData[0].Temp.Min {
+		t.Errorf("Expected min temperature %f, got %f", dailyData[0].Temp.Min, *forecast[0].Temperature.TempMin)
 	}
-	if forecast[0].Temperature.TempMax != dailyData[0].Temp.Max {
-		t.Errorf("Expected max temperature %f, got %f", dailyData[0].Temp.Max, forecast[0].Temperature.TempMax)
+	if *forecast[0].Temperature.TempMax != dailyData[0].Temp.Max {
+		t.Errorf("Expected max temperature %f, got %f", dailyData[0].Temp.Max, *forecast[0].Temperature.TempMax)
 	}
 	if forecast[0].Status != dailyData[0].Weather[0].Main {
 		t.Errorf("Expected status %s, got %s", dailyData[0].Weather[0].Main, forecast[0].Status)
@@ -291,6 +292,8 @@ func TestHandleGetWeather(t *testing.T) {
 			WindDeg    float64               `json:"wind_deg"`
 			WindGust   float64               `json:"wind_gust"`
 			Weather    []models.OWMCondition `json:"weather"`
+			Rain       models.OWMVolume      `json:"rain"`
+			Snow       models.OWMVolume      `json:"snow"`
 		}{
 			Timestamp: 1641456000,
 			Temp:      20.5,
@@ -391,3 +394,174 @@ func TestHandleGetWeather(t *testing.T) {
 		t.Error("Expected non-zero temperature")
 	}
 }
+
+func TestHandleGetWeatherServesStaleSnapshot(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	mockResponse := models.OWMWeatherData{
+		Lat: 37.7749,
+		Lon: -122.4194,
+		Daily: []models.OWMDailyForecast{
+			{Weather: []models.OWMCondition{{ID: 500, Main: "Rain"}}},
+		},
+	}
+	mockResponse.Current.Temp = 12.5
+	mockResponse.Current.Weather = []models.OWMCondition{{ID: 500, Main: "Rain"}}
+
+	online := &mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			responseBody, _ := json.Marshal(mockResponse)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader(responseBody)),
+				Header:     make(http.Header),
+			}, nil
+		},
+	}
+	offline := &mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusUnauthorized,
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+				Header:     make(http.Header),
+			}, nil
+		},
+	}
+
+	svc, err := NewService()
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	svc.SetHTTPClient(online)
+	svc.HandleGetWeather(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/weather", nil))
+
+	// A fresh service simulates a restart while the network is down
+	restarted, err := NewService()
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	restarted.SetHTTPClient(offline)
+
+	w := httptest.NewRecorder()
+	restarted.HandleGetWeather(w, httptest.NewRequest(http.MethodGet, "/api/weather", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response models.WeatherData
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !response.Stale {
+		t.Error("Expected response to be flagged as stale")
+	}
+	if response.Temperature.Temp != 12.5 || response.Status != "Rain" {
+		t.Errorf("Expected the snapshot weather, got %+v", response)
+	}
+}
+
+func TestHandleGetWeatherWithoutSnapshot(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	svc, err := NewService()
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	svc.SetHTTPClient(&mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusUnauthorized,
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+				Header:     make(http.Header),
+			}, nil
+		},
+	})
+
+	w := httptest.NewRecorder()
+	svc.HandleGetWeather(w, httptest.NewRequest(http.MethodGet, "/api/weather", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestHandleGetWeatherServesBackgroundSnapshot(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	mockResponse := models.OWMWeatherData{
+		Daily: []models.OWMDailyForecast{
+			{Weather: []models.OWMCondition{{ID: 800, Main: "Clear"}}},
+		},
+	}
+	mockResponse.Current.Temp = 21
+	mockResponse.Current.Weather = []models.OWMCondition{{ID: 800, Main: "Clear"}}
+
+	requests := 0
+	svc, err := NewService()
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	svc.SetHTTPClient(&mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			requests++
+			responseBody, _ := json.Marshal(mockResponse)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader(responseBody)),
+				Header:     make(http.Header),
+			}, nil
+		},
+	})
+
+	if err := svc.refresh(context.Background()); err != nil {
+		t.Fatalf("Failed to refresh: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		svc.HandleGetWeather(w, httptest.NewRequest(http.MethodGet, "/api/weather", nil))
+
+		var response models.WeatherData
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.FetchedAt == 0 {
+			t.Error("Expected fetched_at to be set")
+		}
+	}
+
+	if requests != 1 {
+		t.Errorf("Expected handlers to be served from the snapshot with 1 upstream request, got %d", requests)
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	testCases := []struct {
+		provider string
+		expected Provider
+	}{
+		{"", &owmProvider{}},
+		{providerOpenWeatherMap, &owmProvider{}},
+		{providerOpenMeteo, &openMeteoProvider{}},
+		{providerNWS, &nwsProvider{}},
+	}
+
+	for _, tc := range testCases {
+		provider, err := newProvider(models.WeatherSettings{Provider: tc.provider}, retry.DefaultConfig())
+		if err != nil {
+			t.Errorf("Failed to create provider %q: %v", tc.provider, err)
+			continue
+		}
+		if fmt.Sprintf("%T", provider) != fmt.Sprintf("%T", tc.expected) {
+			t.Errorf("Expected %T for %q, got %T", tc.expected, tc.provider, provider)
+		}
+	}
+
+	if _, err := newProvider(models.WeatherSettings{Provider: "darksky"}, retry.DefaultConfig()); err == nil {
+		t.Error("Expected an error for an unknown provider")
+	}
+}
@@ -10,6 +10,7 @@ vi.mock('./Weather', () => ({
 // Mock calendar and weather data
 const mockCalendarData = {
   startDate: '2024-01-15T00:00:00.000Z', // Match our test date
+  endDate: '2024-01-29T00:00:00.000Z',
   startOnSunday: true,
   numberOfWeeks: 2,
   events: []
@@ -149,6 +150,38 @@ describe('Calendar', () => {
     expect(screen.getByText(monthYear)).toBeInTheDocument();
   });
 
+  it('renders only the days of the window', async () => {
+    // number_of_days 10 is served as two week rows
+    const daysModeData = { ...mockCalendarData, endDate: '2024-01-25T00:00:00.000Z' };
+    global.fetch = vi.fn().mockImplementation((url: string) => {
+      if (url.includes('/api/calendar')) {
+        return Promise.resolve({
+          ok: true,
+          json: () => Promise.resolve(daysModeData),
+        });
+      } else if (url.includes('/api/weather')) {
+        return Promise.resolve({
+          ok: true,
+          json: () => Promise.resolve(mockWeatherData),
+        });
+      }
+      return Promise.reject(new Error('Not found'));
+    });
+
+    await act(async () => {
+      render(<Calendar />);
+    });
+
+    await waitFor(() => {
+      expect(screen.queryByText('Loading...')).not.toBeInTheDocument();
+    });
+
+    // January 15 to 24, without empty cells after the last day
+    expect(screen.getByText('15')).toBeInTheDocument();
+    expect(screen.getByText('24')).toBeInTheDocument();
+    expect(screen.queryByText('25')).not.toBeInTheDocument();
+  });
+
   it('handles fetch error gracefully', async () => {
     // Mock a failed fetch for both endpoints
     global.fetch = vi.fn().mockRejectedValue(new Error('Failed to fetch'));
@@ -1,7 +1,7 @@
 import React, { useState, useEffect } from 'react';
 import './Calendar.scss';
 import CalendarDay from './CalendarDay';
-import { CalendarData, DayData, CalendarEvent } from '../types/calendar';
+import { CalendarData, CalendarResponse, DayData, CalendarEvent } from '../types/calendar';
 import { ForecastData } from '../types/weather';
 import Weather from './Weather';
 
@@ -43,18 +43,25 @@ const Calendar: React.FC<{ headerPosition?: 'top' | 'bottom' }> = ({ headerPosit
   useEffect(() => {
     const fetchCalendar = async () => {
       try {
-        const response = await fetch(`${API_URL}/api/calendar`);
+        // A display opened with ?person=Emma only shows that person's events
+        const person = new URLSearchParams(window.location.search).get('person');
+        const query = person ? `?person=${encodeURIComponent(person)}` : '';
+        const response = await fetch(`${API_URL}/api/calendar${query}`);
         if (!response.ok) {
           throw new Error('Failed to fetch calendar data');
         }
-        const { startDate, startOnSunday, numberOfWeeks, events } = await response.json();
+        const { startDate, endDate, startOnSunday, numberOfWeeks, events }: CalendarResponse = await response.json();
 
         const start = new Date(startDate);
         const currentWeekStart = new Date(start);
         // Reset time to midnight so all-day events are displayed correctly
         currentWeekStart.setHours(0, 0, 0, 0);
 
-        const days: DayData[] = Array.from({ length: numberOfWeeks * 7 }, (_, index) => {
+        // Only the days of the window are drawn; in "days" mode the last week
+        // row may be partial. Rounding absorbs DST changes in between.
+        const numberOfDays = Math.round((new Date(endDate).getTime() - start.getTime()) / (24 * 60 * 60 * 1000));
+
+        const days: DayData[] = Array.from({ length: numberOfDays }, (_, index) => {
           const date = new Date(currentWeekStart);
           date.setDate(currentWeekStart.getDate() + index);
           return {
@@ -86,7 +93,7 @@ const Calendar: React.FC<{ headerPosition?: 'top' | 'bottom' }> = ({ headerPosit
 
         setCalendarData({
           startDate: startDate,
-          endDate: new Date(start.getFullYear(), start.getMonth() + 6, 0).toISOString(),
+          endDate: endDate,
           startOnSunday: startOnSunday,
           numberOfWeeks: numberOfWeeks,
           events: days
@@ -122,7 +129,13 @@ const Calendar: React.FC<{ headerPosition?: 'top' | 'bottom' }> = ({ headerPosit
     month: 'short',
     year: 'numeric'
   }).toUpperCase();
-  const daysOfWeek = calendarData.startOnSunday ? ['Sun', 'Mon', 'Tue', 'Wed', 'Thu', 'Fri', 'Sat'] : ['Mon', 'Tue', 'Wed', 'Thu', 'Fri', 'Sat', 'Sun'];
+  // The columns follow the first day, which is today rather than the start of
+  // the week in "days" mode
+  const weekdayNames = ['Sun', 'Mon', 'Tue', 'Wed', 'Thu', 'Fri', 'Sat'];
+  const firstWeekday = calendarData.events.length > 0
+    ? new Date(calendarData.events[0].date).getDay()
+    : (calendarData.startOnSunday ? 0 : 1);
+  const daysOfWeek = weekdayNames.map((_, index) => weekdayNames[(firstWeekday + index) % 7]);
 
   const renderHeader = () => (
     <div className="col-span-7 bg-white px-2 py-1 flex justify-between items-center relative z-10">
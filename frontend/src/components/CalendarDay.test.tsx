@@ -78,6 +78,9 @@ describe('CalendarDay', () => {
         id: '1',
         summary: 'Cardboard',
         colorId: 'recycling',
+        category: 'recycling-cardboard',
+        icon: 'cardboard-recycling',
+        badge: true,
         start: { date: testDate.toISOString().split('T')[0], timeZone: 'UTC' },
         end: { date: testDate.toISOString().split('T')[0], timeZone: 'UTC' }
       }]
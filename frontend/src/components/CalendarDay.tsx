@@ -2,6 +2,7 @@ import React from 'react';
 import Event from './Event';
 import { DayData } from '../types/calendar';
 import { cn } from "@/lib/utils"
+import { iconMap } from '../lib/icons';
 import 'weather-icons-npm/css/weather-icons.css';
 
 interface CalendarDayProps {
@@ -17,9 +18,9 @@ const CalendarDay: React.FC<CalendarDayProps> = ({ dayData, calendarData, dailyF
   const dayNumber = new Date(dayData.date).getDate();
   const events = dayData.events || [];
 
-  // Filter out recycling events from the regular event list
+  // Badge events, like recycling pickups, are shown next to the day number
   const regularEvents = events
-    .filter(event => event.colorId !== 'recycling')
+    .filter(event => !event.badge)
     .sort((a, b) => {
       // Get start times, using date for all-day events
       const aStart = a.start.dateTime || a.start.date;
@@ -32,21 +33,12 @@ const CalendarDay: React.FC<CalendarDayProps> = ({ dayData, calendarData, dailyF
       return new Date(aStart).getTime() - new Date(bStart).getTime();
     });
 
-  // Check for special recycling events
-  const hasCardboard = events.some(event =>
-    event.colorId === 'recycling' &&
-    event.summary === 'Cardboard'
-  );
-  const hasCans = events.some(event =>
-    event.colorId === 'recycling' &&
-    event.summary === 'Cans' &&
-    !event.start?.dateTime
-  );
-  const hasTrash = events.some(event =>
-    event.colorId === 'recycling' &&
-    event.summary === 'Trash' &&
-    !event.start?.dateTime
-  );
+  // One badge per icon, in the order the events come
+  const badgeIcons = [...new Set(
+    events
+      .filter(event => event.badge && event.icon && iconMap[event.icon])
+      .map(event => event.icon as string)
+  )];
 
   // Find matching weather forecast for this day
   const weatherForDay = dailyForecast?.find(forecast => {
@@ -78,11 +70,11 @@ const CalendarDay: React.FC<CalendarDayProps> = ({ dayData, calendarData, dailyF
             <i className={`wi wi-owm-${weatherForDay.icon} ml-1 pt-1 text-sm`} style={{ marginTop: '-2px' }}></i>
           )}
         </div>
-        {(hasCardboard || hasCans || hasTrash) && (
+        {badgeIcons.length > 0 && (
           <div className="flex flex-col gap-0.5 ml-2 text-base" style={{ fontFamily: "'Segoe UI Emoji', 'Apple Color Emoji', 'Noto Color Emoji', sans-serif" }}>
-            {hasCardboard && <span className="emoji" title="Cardboard Recycling">📦♻️</span>}
-            {hasCans && <span className="emoji" title="Can Recycling">🥤♻️</span>}
-            {hasTrash && <span className="emoji" title="Trash Pickup">🗑️</span>}
+            {badgeIcons.map(icon => (
+              <span key={icon} className="emoji" title={iconMap[icon].title}>{iconMap[icon].emoji}</span>
+            ))}
           </div>
         )}
       </div>
@@ -1,6 +1,7 @@
 import React from 'react';
 import { CalendarEvent } from '../types/calendar';
 import { bgColorMap, borderColorMap, textColorMap } from '../lib/colormap';
+import { iconMap } from '../lib/icons';
 import { cn, formatTime } from "@/lib/utils"
 
 interface EventProps {
@@ -14,6 +15,7 @@ const Event: React.FC<EventProps> = ({ event, totalEvents }) => {
   const eventBgColor = bgColorMap[event.colorId as keyof typeof bgColorMap] || bgColorMap['black'];
   const eventBorderColor = borderColorMap[event.colorId as keyof typeof borderColorMap] || borderColorMap['black'];
   const isCompactView = totalEvents > 3;
+  const icon = event.icon ? iconMap[event.icon] : undefined;
 
   return (
     <div className={cn(
@@ -27,6 +29,21 @@ const Event: React.FC<EventProps> = ({ event, totalEvents }) => {
           {formatTime(event.start.dateTime)}
         </span>
       )}
+      {icon && (
+        <span className="emoji mr-0.5" title={icon.title}>{icon.emoji}</span>
+      )}
+      {event.people?.map(person => (
+        <span
+          key={person.name}
+          className={cn(
+            'inline-block rounded-full px-0.5 mr-0.5 text-white',
+            bgColorMap[person.color as keyof typeof bgColorMap] || bgColorMap['black']
+          )}
+          title={person.name}
+        >
+          {person.initials || person.name.charAt(0)}
+        </span>
+      ))}
       <span className={`${isAllDay ? 'text-white' : textColorMap[event.colorId as keyof typeof textColorMap] || textColorMap['black']}`}>
         {event.summary}
       </span>
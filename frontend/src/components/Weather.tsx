@@ -42,6 +42,13 @@ const Weather: React.FC = () => {
 
   if (!currentWeather) return null;
 
+  // Alerts in effect, most severe first as sorted by the backend
+  const activeAlerts = (currentWeather.alerts || []).filter(alert => alert.active);
+
+  // Only mention the next hour when there is precipitation in it
+  const nowcast = currentWeather.nowcast;
+  const nowcastSummary = nowcast && (nowcast.precipitating || nowcast.starts_at) ? nowcast.summary : '';
+
   return (
     <div className="flex flex-col items-center font-weather">
       <div className="flex items-center gap-0.5 text-black-900">
@@ -51,10 +58,12 @@ const Weather: React.FC = () => {
             <div className="text-xl font-bold">
               {Math.round(currentWeather.temperature.temp)}°
             </div>
-            <div className="flex flex-row items-end gap-0.5 pl-1">
-              <span className="text-red-500 font-bold">{Math.round(currentWeather.temperature.max)}°</span>/
-              <span className="text-blue-500 font-bold">{Math.round(currentWeather.temperature.min)}°</span>
-            </div>
+            {currentWeather.temperature.max !== undefined && currentWeather.temperature.min !== undefined && (
+              <div className="flex flex-row items-end gap-0.5 pl-1">
+                <span className="text-red-500 font-bold">{Math.round(currentWeather.temperature.max)}°</span>/
+                <span className="text-blue-500 font-bold">{Math.round(currentWeather.temperature.min)}°</span>
+              </div>
+            )}
             <div className="font-bold pl-1">
               {currentWeather.humidity}<i className="ml-0.25 wi wi-humidity" style={{ fontSize: '0.9rem' }}></i>
             </div>
@@ -63,6 +72,11 @@ const Weather: React.FC = () => {
             </div>
           </div>
           <p className="text-center font-bold" style={{ fontSize: '0.6rem', marginTop: '-0.25rem' }}>{titleCase(currentWeather.detailed_status)}</p>
+          {nowcastSummary && (
+            <p className="text-center font-bold text-blue-800" style={{ fontSize: '0.6rem' }}>
+              <i className="wi wi-umbrella mr-0.5"></i>{nowcastSummary}
+            </p>
+          )}
         </div>
         <div className="flex gap-0.5 border-l border-blue-800 pl-0.5 ml-0.75">
           {hourlyForecast.filter((_, index) => index % 2 === 0).slice(0, 6).map((item, index) => (
@@ -80,6 +94,12 @@ const Weather: React.FC = () => {
           ))}
         </div>
       </div>
+      {activeAlerts.length > 0 && (
+        <div className="w-full text-center font-bold text-white bg-red-600 rounded-sm px-1" style={{ fontSize: '0.7rem' }}>
+          <i className="wi wi-storm-warning mr-1"></i>
+          {activeAlerts.map(alert => alert.event).join(' · ')}
+        </div>
+      )}
     </div>
   );
 };
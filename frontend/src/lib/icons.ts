@@ -0,0 +1,10 @@
+// Emoji for the icon names the backend assigns to event categories
+export const iconMap: Record<string, { emoji: string; title: string }> = {
+  'cardboard-recycling': { emoji: '📦♻️', title: 'Cardboard Recycling' },
+  'can-recycling': { emoji: '🥤♻️', title: 'Can Recycling' },
+  trash: { emoji: '🗑️', title: 'Trash Pickup' },
+  birthday: { emoji: '🎂', title: 'Birthday' },
+  sports: { emoji: '⚽', title: 'Sports' },
+  school: { emoji: '🏫', title: 'School' },
+  doctor: { emoji: '🩺', title: 'Doctor' },
+};
@@ -1,9 +1,46 @@
+// Response of /api/calendar. The JSON contract is versioned by `version`,
+// matching CalendarAPIVersion in backend-go/models/models.go.
+export interface CalendarResponse {
+  version: number;
+  startDate: string;
+  endDate: string;
+  startOnSunday: boolean;
+  numberOfWeeks: number;
+  timezone: string;
+  events: CalendarEvent[];
+  calendarStatus: CalendarStatus[];
+  stale: boolean;
+  truncated: boolean;
+  fetchedAt: string;
+}
+
+// Outcome of the last fetch of one calendar
+export interface CalendarStatus {
+  calendar: string;
+  color: string;
+  status: 'ok' | 'error';
+  error?: string;
+  lastSuccess?: string;
+  stale?: boolean;
+  ageSeconds?: number;
+  truncated?: boolean;
+  hiddenEvents?: number;
+}
+
+// Day grid, as served by /api/calendar/grid or built from CalendarResponse.
+// The fields from version on are only set when served by the backend.
 export interface CalendarData {
   startDate: string;
   endDate: string;
   startOnSunday: boolean;
   numberOfWeeks: number;
+  timezone?: string;
   events: DayData[];
+  version?: number;
+  calendarStatus?: CalendarStatus[];
+  stale?: boolean;
+  truncated?: boolean;
+  fetchedAt?: string;
 }
 
 export interface DayData {
@@ -11,6 +48,14 @@ export interface DayData {
   events: CalendarEvent[];
   isToday: boolean;
   isCurrentMonth: boolean;
+  lanes?: EventLane[];
+}
+
+export interface EventLane {
+  eventId: string;
+  lane: number;
+  continuesFrom: boolean;
+  continuesInto: boolean;
 }
 
 export interface CalendarEvent {
@@ -21,12 +66,31 @@ export interface CalendarEvent {
   start: {
     date?: string;
     dateTime?: string;
-    timeZone: string;
+    timeZone?: string;
   };
   end: {
     date?: string;
     dateTime?: string;
-    timeZone: string;
+    timeZone?: string;
   };
   colorId?: string;
+  calendarColor?: string;
+  eventColor?: string;
+  colors?: string[];
+  calendars?: EventCalendar[];
+  category?: string;
+  icon?: string;
+  badge?: boolean;
+  people?: PersonTag[];
+}
+
+export interface EventCalendar {
+  name: string;
+  color?: string;
+}
+
+export interface PersonTag {
+  name: string;
+  initials?: string;
+  color?: string;
 }
\ No newline at end of file
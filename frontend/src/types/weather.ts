@@ -2,8 +2,8 @@ export interface WeatherData {
   temperature: {
     temp: number;
     feels_like: number;
-    min: number;
-    max: number;
+    min?: number;
+    max?: number;
   };
   status: string;
   detailed_status: string;
@@ -13,6 +13,42 @@ export interface WeatherData {
   summary: string;
   hourly_forecast: ForecastData[];
   daily_forecast: ForecastData[];
+  pop: number;
+  rain?: number;
+  snow?: number;
+  astronomy?: Astronomy;
+  alerts?: WeatherAlert[];
+  nowcast?: Nowcast;
+  warnings?: string[];
+}
+
+export interface Astronomy {
+  sunrise: number;
+  sunset: number;
+  daylight_seconds: number;
+  moonrise: number;
+  moonset: number;
+  moon_phase: number;
+  moon_phase_name: string;
+}
+
+export interface Nowcast {
+  summary: string;
+  precipitating: boolean;
+  starts_at?: number;
+  stops_at?: number;
+  peak_intensity: number;
+  minutes: { timestamp: number; precipitation: number }[];
+}
+
+export interface WeatherAlert {
+  sender: string;
+  event: string;
+  start: number;
+  end: number;
+  description: string;
+  severity: 'extreme' | 'severe' | 'moderate' | 'minor' | 'unknown';
+  active: boolean;
 }
 
 export interface ForecastData {
@@ -20,12 +56,17 @@ export interface ForecastData {
   temperature: {
     temp: number;
     feels_like: number;
-    min: number;
-    max: number;
+    min?: number;
+    max?: number;
   };
   status: string;
   icon: string;
   humidity: number;
   wind_speed: number;
   summary: string;
+  pop: number;
+  rain?: number;
+  snow?: number;
+  uvi: number;
+  astronomy?: Astronomy;
 }